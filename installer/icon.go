@@ -0,0 +1,87 @@
+package installer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 替换 stub 图标：.ico 文件中的每幅图像写为一个 RT_ICON 资源，再写一个引用它们的 RT_GROUP_ICON，
+// 资源管理器与任务栏使用 exe 中的第一个图标组。
+
+// icoImage .ico 中的一幅图像
+type icoImage struct {
+	entry [12]byte // ICONDIRENTRY 的前 12 字节（宽、高、颜色数、保留、平面数、位深、数据大小），与 GRPICONDIRENTRY 相同
+	data  []byte
+}
+
+// parseICO 解析 .ico 文件
+func parseICO(data []byte) ([]icoImage, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[0:]) != 0 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, fmt.Errorf("not an .ico file")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 || len(data) < 6+16*count {
+		return nil, fmt.Errorf("truncated .ico directory")
+	}
+	images := make([]icoImage, count)
+	for i := range images {
+		e := data[6+16*i : 6+16*(i+1)]
+		size := binary.LittleEndian.Uint32(e[8:])
+		offset := binary.LittleEndian.Uint32(e[12:])
+		if uint64(offset)+uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("image %d exceeds .ico file", i)
+		}
+		copy(images[i].entry[:], e[:12])
+		images[i].data = data[offset : offset+size]
+	}
+	return images, nil
+}
+
+// groupIconDir 生成 RT_GROUP_ICON 资源：第 i 幅图像对应 ID 为 i+1 的 RT_ICON
+func groupIconDir(images []icoImage) []byte {
+	b := make([]byte, 6, 6+14*len(images))
+	binary.LittleEndian.PutUint16(b[2:], 1)
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(images)))
+	for i, img := range images {
+		b = append(b, img.entry[:]...)
+		b = binary.LittleEndian.AppendUint16(b, uint16(i+1))
+	}
+	return b
+}
+
+// stubWithIcon 复制 stub 到临时文件并将其图标替换为 iconFile，返回临时文件路径与清理函数
+func stubWithIcon(stubExe, iconFile string) (string, func(), error) {
+	data, err := os.ReadFile(iconFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("read icon: %w", err)
+	}
+	images, err := parseICO(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("icon %s: %w", iconFile, err)
+	}
+
+	src, err := os.Open(stubExe)
+	if err != nil {
+		return "", nil, fmt.Errorf("read stub: %w", err)
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp("", "stub-*.exe")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.Remove(tmp.Name()) }
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = setExeIcon(tmp.Name(), images)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("set stub icon: %w", err)
+	}
+	return tmp.Name(), cleanup, nil
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package installer
+
+import "errors"
+
+// setExeIcon 依赖 Windows 的资源更新 API；交叉编译时请改用 windres / rsrc 把图标编入 stub_windows.syso
+func setExeIcon(exePath string, images []icoImage) error {
+	return errors.New("replacing the stub icon requires packaging on Windows; embed the icon into stub_windows.syso with windres or rsrc instead")
+}
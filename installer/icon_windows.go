@@ -0,0 +1,53 @@
+//go:build windows
+
+package installer
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procBeginUpdateResource = modkernel32.NewProc("BeginUpdateResourceW")
+	procUpdateResource      = modkernel32.NewProc("UpdateResourceW")
+	procEndUpdateResource   = modkernel32.NewProc("EndUpdateResourceW")
+)
+
+const (
+	rtIcon      = 3
+	rtGroupIcon = 14
+)
+
+// setExeIcon 通过 BeginUpdateResource / UpdateResource 写入图标资源（保留 manifest 等已有资源）。
+// 必须在追加归档之前调用：EndUpdateResource 会重写 PE 文件，不保留文件末尾的附加数据。
+func setExeIcon(exePath string, images []icoImage) error {
+	name, err := syscall.UTF16PtrFromString(exePath)
+	if err != nil {
+		return err
+	}
+	h, _, err := procBeginUpdateResource.Call(uintptr(unsafe.Pointer(name)), 0)
+	if h == 0 {
+		return err
+	}
+	update := func(typ, id uintptr, data []byte) error {
+		if r, _, err := procUpdateResource.Call(h, typ, id, 0, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data))); r == 0 {
+			return err
+		}
+		return nil
+	}
+	for i, img := range images {
+		if err := update(rtIcon, uintptr(i+1), img.data); err != nil {
+			procEndUpdateResource.Call(h, 1) // 放弃修改
+			return err
+		}
+	}
+	if err := update(rtGroupIcon, 1, groupIconDir(images)); err != nil {
+		procEndUpdateResource.Call(h, 1)
+		return err
+	}
+	if r, _, err := procEndUpdateResource.Call(h, 0); r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package kernel
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InMemoryFile 是归档中的一个条目（目录以 "/" 结尾，Data 为 nil）
+type InMemoryFile struct {
+	Name    string
+	Mode    int64
+	Data    []byte
+	ModTime time.Time // 归档中记录的修改时间
+}
+
+// ArchiveLimits 限制解包时的资源占用，防止恶意构造的压缩包（解压炸弹）耗尽内存
+type ArchiveLimits struct {
+	MaxTotalSize int64 // 解压后 tar 流的总大小上限（字节）
+	MaxFiles     int   // 条目数量上限（文件 + 目录）
+	// Select 可选的条目筛选（如 InstallMeta.PlatformSelector）：返回条目安装时的路径及是否保留，
+	// 不保留的条目不交给回调；nil 表示全部按原路径保留。上限按筛选前的全部条目计算。
+	Select func(name string) (string, bool)
+}
+
+// DefaultArchiveLimits 为 stub 使用的默认限制
+var DefaultArchiveLimits = ArchiveLimits{
+	MaxTotalSize: 2 << 30, // 2 GiB
+	MaxFiles:     65536,
+}
+
+// UntarGzToMemory 将 tar.gz 数据完整解包到内存
+func UntarGzToMemory(gzData []byte, limits ArchiveLimits) ([]*InMemoryFile, error) {
+	var out []*InMemoryFile
+	err := walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		if h.Typeflag == tar.TypeDir {
+			// 目录延迟创建
+			out = append(out, &InMemoryFile{Name: name + "/", Mode: h.Mode, ModTime: h.ModTime})
+			return nil
+		}
+		buf := &bytes.Buffer{}
+		if _, err := io.Copy(buf, r); err != nil {
+			return err
+		}
+		out = append(out, &InMemoryFile{Name: name, Mode: h.Mode, Data: buf.Bytes(), ModTime: h.ModTime})
+		return nil
+	})
+	return out, err
+}
+
+// errStopWalk 由 walkTarGz 的回调返回，表示提前结束遍历
+var errStopWalk = errors.New("stop walk")
+
+// walkTarGz 按顺序遍历归档中的普通文件与目录条目（其他类型忽略），对每个条目调用 fn；
+// name 为经 LocalPath 校验后的规范路径，r 为条目内容。遍历受 limits 约束，fn 返回 errStopWalk 时提前结束。
+func walkTarGz(gzData []byte, limits ArchiveLimits, fn func(h *tar.Header, name string, r io.Reader) error) error {
+	gzr, err := gzip.NewReader(bytes.NewReader(gzData))
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	// 多读 1 字节用于判断是否超限：读满上限即视为超限
+	lr := &io.LimitedReader{R: gzr, N: limits.MaxTotalSize + 1}
+	exceeded := func(err error) error {
+		if lr.N <= 0 {
+			return fmt.Errorf("归档解压后超过大小上限 %d 字节", limits.MaxTotalSize)
+		}
+		return err
+	}
+
+	tr := tar.NewReader(lr)
+	count := 0
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return exceeded(err)
+		}
+		if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeDir {
+			continue
+		}
+		if count >= limits.MaxFiles {
+			return fmt.Errorf("归档条目数超过上限 %d", limits.MaxFiles)
+		}
+		count++
+		if h.Size > limits.MaxTotalSize {
+			return fmt.Errorf("归档条目 %s 大小 %d 超过上限 %d 字节", h.Name, h.Size, limits.MaxTotalSize)
+		}
+		// 条目名必须位于安装目录之内，防止 "../" 或绝对路径写到目录外
+		name, err := LocalPath(h.Name)
+		if err != nil {
+			return fmt.Errorf("归档条目 %q 路径不安全: %w", h.Name, err)
+		}
+		if limits.Select != nil {
+			var keep bool
+			if name, keep = limits.Select(name); !keep {
+				continue
+			}
+		}
+		if err := fn(h, name, tr); err != nil {
+			if errors.Is(err, errStopWalk) {
+				return nil
+			}
+			return exceeded(err)
+		}
+	}
+}
+
+// LocalPath 校验 name 是相对安装目录的路径（允许子目录，如 "bin/app.exe"，\ 与 / 均视为分隔符），
+// 返回以 / 分隔的规范形式；空路径、绝对路径、盘符以及经 ".." 跳出目录的路径一律拒绝。
+func LocalPath(name string) (string, error) {
+	n := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if n == "." || strings.Contains(n, ":") || !filepath.IsLocal(filepath.FromSlash(n)) {
+		return "", fmt.Errorf("%q is not a relative path inside the install directory", name)
+	}
+	return n, nil
+}
+
+// FindFile 按名称查找归档条目，找不到返回 nil；名称前的 "./" 与 \ 分隔符不影响匹配
+func FindFile(files []*InMemoryFile, name string) *InMemoryFile {
+	name = cleanEntryName(name)
+	for _, f := range files {
+		if cleanEntryName(f.Name) == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// cleanEntryName 将条目名规范为不带 "./" 前缀、以 / 分隔的形式，保留目录的结尾 "/"
+func cleanEntryName(name string) string {
+	n := strings.ReplaceAll(name, `\`, "/")
+	dir := strings.HasSuffix(n, "/")
+	n = strings.TrimPrefix(path.Clean("/"+n), "/")
+	if dir && n != "" {
+		n += "/"
+	}
+	return n
+}
+
+// ArchiveStats 统计归档条目中的文件数（不含目录）与解压后总字节数
+func ArchiveStats(files []*InMemoryFile) (count int, size int64) {
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		count++
+		size += int64(len(f.Data))
+	}
+	return count, size
+}
+
+// FormatSize 将字节数格式化为便于阅读的形式，如 "12.3 MB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// BuildTarGz 将 files（归档内路径 -> 内容）打包为 tar.gz；以 "/" 结尾的路径写为目录条目
+func BuildTarGz(files map[string][]byte, compressionLevel int) ([]byte, error) {
+	return BuildTarGzWithTimes(files, nil, compressionLevel)
+}
+
+// BuildTarGzWithTimes 与 BuildTarGz 相同，但条目的修改时间取自 modTimes（缺失时为打包时间）
+func BuildTarGzWithTimes(files map[string][]byte, modTimes map[string]time.Time, compressionLevel int) ([]byte, error) {
+	var buf bytes.Buffer
+	gzw, err := gzip.NewWriterLevel(&buf, compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(gzw)
+
+	// 按名称排序保证归档可复现，meta.json 放在最前以便只读取它时可以提前结束
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "meta.json") != (names[j] == "meta.json") {
+			return names[i] == "meta.json"
+		}
+		return names[i] < names[j]
+	})
+
+	now := time.Now()
+	for _, name := range names {
+		data := files[name]
+		h := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if t, ok := modTimes[name]; ok {
+			h.ModTime = t
+		}
+		// 对于 exe 给予执行权限（在 *nix 上）
+		if filepath.Ext(strings.ToLower(name)) == ".exe" {
+			h.Mode = 0o755
+		}
+		if strings.HasSuffix(name, "/") {
+			h.Name = strings.TrimSuffix(name, "/")
+			h.Typeflag = tar.TypeDir
+			h.Mode = 0o755
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			tw.Close()
+			gzw.Close()
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			tw.Close()
+			gzw.Close()
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		gzw.Close()
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,194 @@
+package kernel
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+// rawTarGz 按给定顺序写出归档，条目名不做任何校验（用于构造恶意归档）
+func rawTarGz(t *testing.T, entries ...tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, h := range entries {
+		if h.Typeflag == 0 {
+			h.Typeflag = tar.TypeReg
+		}
+		h.Mode = 0o644
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(make([]byte, h.Size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUntarGzToMemoryLimits(t *testing.T) {
+	limits := ArchiveLimits{MaxTotalSize: 64 << 10, MaxFiles: 3}
+
+	tests := []struct {
+		name      string
+		archive   []byte
+		limits    ArchiveLimits
+		wantNames []string
+		wantErr   string
+	}{
+		{
+			name: "within limits",
+			archive: rawTarGz(t,
+				tar.Header{Name: "bin", Typeflag: tar.TypeDir},
+				tar.Header{Name: "bin/app.exe", Size: 10},
+				tar.Header{Name: "./readme.txt", Size: 10},
+			),
+			limits:    limits,
+			wantNames: []string{"bin/", "bin/app.exe", "readme.txt"},
+		},
+		{
+			name:    "too many entries",
+			archive: rawTarGz(t, tar.Header{Name: "a"}, tar.Header{Name: "b"}, tar.Header{Name: "c"}, tar.Header{Name: "d"}),
+			limits:  limits,
+			wantErr: "条目数超过上限",
+		},
+		{
+			name:    "entry larger than limit",
+			archive: rawTarGz(t, tar.Header{Name: "big.bin", Size: 65 << 10}),
+			limits:  limits,
+			wantErr: "超过上限",
+		},
+		{
+			name:    "total size over limit",
+			archive: rawTarGz(t, tar.Header{Name: "a", Size: 40 << 10}, tar.Header{Name: "b", Size: 40 << 10}),
+			limits:  limits,
+			wantErr: "超过大小上限",
+		},
+		{
+			name:    "parent traversal",
+			archive: rawTarGz(t, tar.Header{Name: "../evil.exe", Size: 1}),
+			limits:  limits,
+			wantErr: "路径不安全",
+		},
+		{
+			name:    "nested traversal",
+			archive: rawTarGz(t, tar.Header{Name: `bin\..\..\evil.exe`, Size: 1}),
+			limits:  limits,
+			wantErr: "路径不安全",
+		},
+		{
+			name:    "absolute path",
+			archive: rawTarGz(t, tar.Header{Name: "/etc/evil", Size: 1}),
+			limits:  limits,
+			wantErr: "路径不安全",
+		},
+		{
+			name:    "drive letter",
+			archive: rawTarGz(t, tar.Header{Name: "C:/evil.exe", Size: 1}),
+			limits:  limits,
+			wantErr: "路径不安全",
+		},
+		{
+			name:    "other entry types are ignored",
+			archive: rawTarGz(t, tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}, tar.Header{Name: "a"}),
+			limits:  limits,
+			// 符号链接既不解出也不计数
+			wantNames: []string{"a"},
+		},
+		{
+			name:    "select renames and drops entries",
+			archive: rawTarGz(t, tar.Header{Name: "x64/app.exe"}, tar.Header{Name: "arm64/app.exe"}),
+			limits: ArchiveLimits{MaxTotalSize: 64 << 10, MaxFiles: 2, Select: func(name string) (string, bool) {
+				rest, ok := strings.CutPrefix(name, "x64/")
+				return rest, ok
+			}},
+			wantNames: []string{"app.exe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := UntarGzToMemory(tt.archive, tt.limits)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UntarGzToMemory() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UntarGzToMemory() error = %v", err)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("UntarGzToMemory() names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestUntarGzToMemoryBomb(t *testing.T) {
+	// 全零内容压缩率极高：4 个 4 MiB 的条目（共 16 MiB）压缩后只有几十 KiB，单个条目都不超限
+	bomb := make(map[string][]byte)
+	for _, name := range []string{"a.bin", "b.bin", "c.bin", "d.bin"} {
+		bomb[name] = make([]byte, 4<<20)
+	}
+	archive, err := BuildTarGz(bomb, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive) > 1<<20 {
+		t.Fatalf("bomb fixture is %d bytes, expected it to compress well", len(archive))
+	}
+	limits := ArchiveLimits{MaxTotalSize: 10 << 20, MaxFiles: 100}
+
+	if _, err := UntarGzToMemory(archive, limits); err == nil || !strings.Contains(err.Error(), "超过大小上限") {
+		t.Errorf("UntarGzToMemory() error = %v, want total size limit", err)
+	}
+	if _, _, err := ScanArchiveStats(archive, limits); err == nil || !strings.Contains(err.Error(), "超过大小上限") {
+		t.Errorf("ScanArchiveStats() error = %v, want total size limit", err)
+	}
+	limits.MaxTotalSize = 32 << 20
+	if _, err := UntarGzToMemory(archive, limits); err != nil {
+		t.Errorf("UntarGzToMemory() with a large enough limit error = %v", err)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "app.exe", want: "app.exe"},
+		{in: "bin/app.exe", want: "bin/app.exe"}, // 允许子目录
+		{in: `bin\app.exe`, want: "bin/app.exe"},
+		{in: "./bin//app.exe", want: "bin/app.exe"},
+		{in: "bin/../app.exe", want: "app.exe"},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "../app.exe", wantErr: true},
+		{in: `..\..\system32\evil.exe`, wantErr: true},
+		{in: "bin/../../evil.exe", wantErr: true},
+		{in: "/usr/bin/evil", wantErr: true},
+		{in: `\Windows\evil.exe`, wantErr: true},
+		{in: `C:\Windows\evil.exe`, wantErr: true},
+		{in: "C:evil.exe", wantErr: true},
+		{in: `\\server\share\evil.exe`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := LocalPath(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("LocalPath(%q) = %q, %v; want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
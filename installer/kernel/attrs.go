@@ -0,0 +1,96 @@
+package kernel
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 文件属性（InstallMeta.FileAttributes 的取值，可用逗号组合，如 "readonly,hidden"）
+const (
+	AttrReadOnly = "readonly" // 只读：Windows 设置 FILE_ATTRIBUTE_READONLY，其他平台去掉写权限
+	AttrHidden   = "hidden"   // 隐藏：仅 Windows 生效
+)
+
+// ParseFileAttributes 解析属性列表
+func ParseFileAttributes(spec string) (readOnly, hidden bool, err error) {
+	for _, a := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(a)) {
+		case AttrReadOnly:
+			readOnly = true
+		case AttrHidden:
+			hidden = true
+		case "":
+		default:
+			return false, false, fmt.Errorf("unknown file attribute %q", a)
+		}
+	}
+	return readOnly, hidden, nil
+}
+
+// ApplyFileAttributes 为安装清单中与 meta.FileAttributes 的路径模式（语法同 path.Match）匹配的文件设置属性，
+// 一个文件匹配多个模式时属性合并。返回设置的文件数。
+func ApplyFileAttributes(installDir string, meta InstallMeta, m Manifest) (int, error) {
+	if len(meta.FileAttributes) == 0 {
+		return 0, nil
+	}
+	paths := make([]string, 0, len(m.Files)+len(m.Generated))
+	for _, e := range m.Files {
+		paths = append(paths, e.Path)
+	}
+	paths = append(paths, m.Generated...)
+
+	n := 0
+	for _, p := range paths {
+		var readOnly, hidden bool
+		for pattern, spec := range meta.FileAttributes {
+			if !matchAny(p, []string{pattern}) {
+				continue
+			}
+			r, h, err := ParseFileAttributes(spec)
+			if err != nil {
+				return n, err
+			}
+			readOnly, hidden = readOnly || r, hidden || h
+		}
+		if !readOnly && !hidden {
+			continue
+		}
+		full, ok := entryPath(installDir, p)
+		if !ok {
+			continue
+		}
+		if err := setFileAttributes(full, readOnly, hidden); err != nil {
+			return n, fmt.Errorf("set attributes of %s: %w", p, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// removeFile 删除文件；只读文件（Windows 上无法直接删除）先去掉只读属性再删除
+func removeFile(p string) error {
+	err := os.Remove(p)
+	if err != nil && !os.IsNotExist(err) {
+		if clearReadOnly(p) == nil {
+			err = os.Remove(p)
+		}
+	}
+	return err
+}
+
+// removeAll 同 os.RemoveAll；失败时去掉其中所有文件的只读属性后重试
+func removeAll(p string) error {
+	if err := os.RemoveAll(p); err == nil {
+		return nil
+	}
+	_ = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			_ = clearReadOnly(path)
+		}
+		return nil
+	})
+	return os.RemoveAll(p)
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package kernel
+
+import "os"
+
+// setFileAttributes 只读即去掉所有写权限；非 Windows 平台没有隐藏属性，忽略
+func setFileAttributes(path string, readOnly, hidden bool) error {
+	if !readOnly {
+		return nil
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, st.Mode().Perm()&^0o222)
+}
+
+// clearReadOnly 恢复所有者的写权限。只处理普通文件与目录：Chmod 会跟随符号链接，
+// 对链接操作会改动链接目标（且 Lstat 报告的 0777 会让目标对所有人可写）；删除链接本身也无需写权限
+func clearReadOnly(path string) error {
+	st, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !st.Mode().IsRegular() && !st.IsDir() {
+		return nil
+	}
+	return os.Chmod(path, st.Mode().Perm()|0o200)
+}
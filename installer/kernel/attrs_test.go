@@ -0,0 +1,124 @@
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileAttributes(t *testing.T) {
+	tests := []struct {
+		spec             string
+		readOnly, hidden bool
+		wantErr          bool
+	}{
+		{spec: ""},
+		{spec: "readonly", readOnly: true},
+		{spec: "hidden", hidden: true},
+		{spec: " ReadOnly , HIDDEN ", readOnly: true, hidden: true},
+		{spec: "readonly,,", readOnly: true},
+		{spec: "system", wantErr: true},
+		{spec: "readonly,archive", wantErr: true},
+	}
+	for _, tt := range tests {
+		r, h, err := ParseFileAttributes(tt.spec)
+		if (err != nil) != tt.wantErr || r != tt.readOnly || h != tt.hidden {
+			t.Errorf("ParseFileAttributes(%q) = %v, %v, %v; want %v, %v, wantErr %v", tt.spec, r, h, err, tt.readOnly, tt.hidden, tt.wantErr)
+		}
+	}
+}
+
+func readOnly(t *testing.T, p string) bool {
+	t.Helper()
+	st, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Mode().Perm()&0o222 == 0
+}
+
+func TestApplyFileAttributes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Demo")
+	installFixture(t, dir, map[string]string{
+		"Demo.exe": "exe", "bin/core.dll": "dll", "LICENSE": "license", "docs/readme.txt": "doc",
+	}, nil, nil)
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, filepath.Dir(dir), map[string]string{"outside.dll": "keep"})
+	m.Files = append(m.Files, ManifestEntry{Path: "../outside.dll"})
+	meta := InstallMeta{FileAttributes: map[string]string{
+		"bin/*.dll": AttrReadOnly,
+		"LICENSE":   "readonly,hidden",
+		"docs/*":    AttrHidden,
+		"../*.dll":  AttrReadOnly,
+		"nomatch.*": AttrReadOnly,
+	}}
+
+	n, err := ApplyFileAttributes(dir, meta, m)
+	if err != nil {
+		t.Fatalf("ApplyFileAttributes() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("ApplyFileAttributes() = %d, want 3", n)
+	}
+	for p, want := range map[string]bool{"bin/core.dll": true, "LICENSE": true, "docs/readme.txt": false, "Demo.exe": false, "../outside.dll": false} {
+		if got := readOnly(t, filepath.Join(dir, filepath.FromSlash(p))); got != want {
+			t.Errorf("%s read-only = %v, want %v", p, got, want)
+		}
+	}
+
+	// 覆盖安装与卸载都须先去掉只读属性
+	if err := WriteFiles([]*InMemoryFile{{Name: "LICENSE", Data: []byte("v2")}}, dir, WriteOptions{}); err != nil {
+		t.Fatalf("WriteFiles() over a read-only file error = %v", err)
+	}
+	if err := RemoveInstalledFiles(dir, m, false); err != nil {
+		t.Fatalf("RemoveInstalledFiles() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("RemoveInstalledFiles() left %d entries", len(entries))
+	}
+
+	bad := InstallMeta{FileAttributes: map[string]string{"*.exe": "bogus"}}
+	if _, err := ApplyFileAttributes(dir, bad, Manifest{Files: []ManifestEntry{{Path: "Demo.exe"}}}); err == nil {
+		t.Error("ApplyFileAttributes() with an unknown attribute should fail")
+	}
+}
+
+func TestClearReadOnlySymlink(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "target.txt")
+	if err := os.WriteFile(target, []byte("keep"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "Demo")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := clearReadOnly(link); err != nil {
+		t.Fatalf("clearReadOnly() error = %v", err)
+	}
+	// 覆盖安装前的处理与卸载同样不得改动链接目标
+	if err := prepareDest(link, OverwriteReplace); err != nil {
+		t.Fatalf("prepareDest() error = %v", err)
+	}
+	if err := RemoveInstalledFiles(dir, Manifest{Files: []ManifestEntry{{Path: "link.txt"}}}, true); err != nil {
+		t.Fatalf("RemoveInstalledFiles() error = %v", err)
+	}
+	if exists(link) {
+		t.Error("the symlink should have been removed")
+	}
+	st, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := st.Mode().Perm(); perm != 0o444 {
+		t.Errorf("symlink target mode = %o, want 444", perm)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package kernel
+
+import "golang.org/x/sys/windows"
+
+// setFileAttributes 在已有属性上追加只读 / 隐藏属性
+func setFileAttributes(path string, readOnly, hidden bool) error {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attrs, err := windows.GetFileAttributes(p)
+	if err != nil {
+		return err
+	}
+	if readOnly {
+		attrs |= windows.FILE_ATTRIBUTE_READONLY
+	}
+	if hidden {
+		attrs |= windows.FILE_ATTRIBUTE_HIDDEN
+	}
+	return windows.SetFileAttributes(p, attrs)
+}
+
+// clearReadOnly 去掉只读属性（隐藏属性不影响删除与覆盖，保留）
+func clearReadOnly(path string) error {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attrs, err := windows.GetFileAttributes(p)
+	if err != nil {
+		return err
+	}
+	if attrs&windows.FILE_ATTRIBUTE_READONLY == 0 {
+		return nil
+	}
+	return windows.SetFileAttributes(p, attrs&^windows.FILE_ATTRIBUTE_READONLY)
+}
@@ -0,0 +1,152 @@
+package kernel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 清理保护：
+//   - systemDirs：系统目录，既不能是它们本身、它们的上级，也不能位于它们之内；
+//   - containerDirs：常见的"容器"目录（Program Files、用户目录等），安装目录可以位于其中，
+//     但不能是它们本身或它们的上级；
+//   - 卷根（C:\、/）与网络共享（\\server\share）一律拒绝；
+//   - 此外解析符号链接后的路径必须位于 installRoots 之内（Windows 为 Program Files、Program Files (x86)、
+//     %LOCALAPPDATA%\Programs，其他平台为用户主目录），打包时设置 AllowAnyInstallDir 可放宽这一条。
+
+func systemDirs() []string {
+	if runtime.GOOS == "windows" {
+		dirs := envDirs("SystemRoot", "windir")
+		for _, d := range dirs {
+			dirs = append(dirs, filepath.Join(d, "System32"), filepath.Join(d, "SysWOW64"))
+		}
+		return dirs
+	}
+	return []string{"/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/sbin", "/sys", "/usr", "/var"}
+}
+
+func containerDirs() []string {
+	dirs := envDirs("ProgramFiles", "ProgramFiles(x86)", "ProgramW6432", "ProgramData",
+		"CommonProgramFiles", "APPDATA", "LOCALAPPDATA", "USERPROFILE", "PUBLIC", "HOME")
+	if sd := os.Getenv("SystemDrive"); sd != "" {
+		dirs = append(dirs, sd+string(filepath.Separator))
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		dirs = append(dirs, home)
+	}
+	if la := os.Getenv("LOCALAPPDATA"); la != "" {
+		dirs = append(dirs, filepath.Join(la, "Programs"))
+	}
+	return append(dirs, os.TempDir())
+}
+
+// installRoots 返回常规安装位置，安装目录须位于其中之一才能被清空（测试中可替换）
+var installRoots = func() []string {
+	if runtime.GOOS == "windows" {
+		dirs := envDirs("ProgramFiles", "ProgramFiles(x86)", "ProgramW6432")
+		if la := os.Getenv("LOCALAPPDATA"); la != "" {
+			dirs = append(dirs, canonicalPath(filepath.Join(la, "Programs")))
+		}
+		return dirs
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return []string{canonicalPath(home)}
+	}
+	return nil
+}
+
+func envDirs(names ...string) []string {
+	var out []string
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			out = append(out, canonicalPath(v))
+		}
+	}
+	return out
+}
+
+// canonicalPath 返回绝对、清理后的路径，存在时进一步解析符号链接
+func canonicalPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	return filepath.Clean(p)
+}
+
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// isWithin 判断 child 是否位于 parent 之内（不含相等）
+func isWithin(child, parent string) bool {
+	if runtime.GOOS == "windows" {
+		child, parent = strings.ToLower(child), strings.ToLower(parent)
+	}
+	rel, err := filepath.Rel(parent, child)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isVolumeRoot(p string) bool {
+	vol := filepath.VolumeName(p)
+	rest := strings.TrimLeft(p[len(vol):], `\/`)
+	return rest == ""
+}
+
+// checkCleanTarget 校验 dir 是否可以被清空：不是受保护目录，且目录名包含产品名
+func checkCleanTarget(dir, productName string, anyDir bool) error {
+	if err := checkProtectedDir(dir, anyDir); err != nil {
+		return err
+	}
+	// 额外保护：目录名本身必须包含产品名（防止 meta 空 productName 或上级目录偶然包含产品名）
+	base := strings.ToLower(filepath.Base(canonicalPath(dir)))
+	if productName == "" || !strings.Contains(base, strings.ToLower(productName)) {
+		return fmt.Errorf("目录不包含产品名，取消清理: %s", dir)
+	}
+	return nil
+}
+
+// checkProtectedDir 拒绝网络共享、卷根、系统目录（及其上级、其内）与容器目录（及其上级）；
+// anyDir 为 false 时还拒绝 installRoots 之外的目录
+func checkProtectedDir(dir string, anyDir bool) error {
+	if strings.HasPrefix(dir, `\\`) || strings.HasPrefix(dir, "//") {
+		return fmt.Errorf("拒绝清理网络共享路径: %s", dir)
+	}
+	p := canonicalPath(dir)
+	if strings.HasPrefix(filepath.VolumeName(p), `\\`) {
+		return fmt.Errorf("拒绝清理网络共享路径: %s", dir)
+	}
+	if isVolumeRoot(p) {
+		return fmt.Errorf("拒绝清理系统根目录: %s", dir)
+	}
+	for _, sys := range systemDirs() {
+		if samePath(p, sys) || isWithin(sys, p) || isWithin(p, sys) {
+			return fmt.Errorf("拒绝清理系统目录: %s", dir)
+		}
+	}
+	for _, c := range containerDirs() {
+		c = canonicalPath(c)
+		if samePath(p, c) || isWithin(c, p) {
+			return fmt.Errorf("拒绝清理受保护目录: %s", dir)
+		}
+	}
+	if anyDir {
+		return nil
+	}
+	for _, r := range installRoots() {
+		if isWithin(p, r) {
+			return nil
+		}
+	}
+	return fmt.Errorf("拒绝清理常规安装位置之外的目录（可在打包时设置 AllowAnyInstallDir）: %s", dir)
+}
@@ -0,0 +1,130 @@
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// installRoot 返回一个临时目录，并在测试期间把它作为唯一的常规安装位置
+func installRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	old := installRoots
+	installRoots = func() []string { return []string{canonicalPath(root)} }
+	t.Cleanup(func() { installRoots = old })
+	return root
+}
+
+// fakeHome 以临时目录充当用户目录（Windows 上还有 %LOCALAPPDATA%），返回其中可以安装的位置
+func fakeHome(t *testing.T) (home, apps string) {
+	t.Helper()
+	home = filepath.Join(t.TempDir(), "home")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	apps = filepath.Join(home, "Apps")
+	if runtime.GOOS == "windows" {
+		t.Setenv("LOCALAPPDATA", filepath.Join(home, "AppData", "Local"))
+		apps = filepath.Join(home, "AppData", "Local", "Programs")
+	}
+	if err := os.MkdirAll(apps, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return home, apps
+}
+
+func TestCheckCleanTarget(t *testing.T) {
+	home, apps := fakeHome(t)
+	safe := filepath.Join(apps, "Demo")
+
+	type guardCase struct {
+		name    string
+		dir     string
+		product string
+		wantErr bool
+	}
+	tests := []guardCase{
+		{"install dir", safe, "Demo", false},
+		{"product name case differs", filepath.Join(apps, "demo-app"), "Demo", false},
+		{"volume root", string(filepath.Separator), "Demo", true},
+		{"UNC share", `\\server\share\Demo`, "Demo", true},
+		{"UNC share with slashes", "//server/share/Demo", "Demo", true},
+		{"home dir", home, "home", true},
+		{"parent of home", filepath.Dir(home), "Demo", true},
+		{"temp dir", os.TempDir(), "Demo", true},
+		{"missing product name", safe, "", true},
+		{"product name only in parent", filepath.Join(apps, "Demo", "bin"), "Demo", true},
+	}
+	if runtime.GOOS == "windows" {
+		windir := os.Getenv("SystemRoot")
+		tests = append(tests, []guardCase{
+			{"drive root", `C:\`, "Demo", true},
+			{"windows dir", windir, "Windows", true},
+			{"system32", filepath.Join(windir, "System32"), "System32", true},
+			{"inside system32", filepath.Join(windir, "System32", "Demo"), "Demo", true},
+			{"program files", os.Getenv("ProgramFiles"), "Program Files", true},
+		}...)
+	} else {
+		tests = append(tests, []guardCase{
+			{"etc", "/etc", "etc", true},
+			{"inside usr", "/usr/local/Demo", "Demo", true},
+		}...)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCleanTarget(tt.dir, tt.product, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCleanTarget(%q, %q) error = %v, wantErr %v", tt.dir, tt.product, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckProtectedDirInstallRoots(t *testing.T) {
+	home, apps := fakeHome(t)
+	outside := filepath.Join(filepath.Dir(home), "Data", "Demo")
+	if err := os.MkdirAll(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	type rootCase struct {
+		name    string
+		dir     string
+		anyDir  bool
+		wantErr bool
+	}
+	tests := []rootCase{
+		{name: "under an install root", dir: filepath.Join(apps, "Demo")},
+		{name: "outside the install roots", dir: outside, wantErr: true},
+		{name: "outside with override", dir: outside, anyDir: true},
+		{name: "install root itself", dir: apps, wantErr: runtime.GOOS == "windows"},
+		{name: "home with override", dir: home, anyDir: true, wantErr: true},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, []rootCase{
+			{name: "program files", dir: filepath.Join(os.Getenv("ProgramFiles"), "Demo")},
+			{name: "other drive", dir: `D:\Data\Demo`, wantErr: true},
+			{name: "other drive with override", dir: `D:\Data\Demo`, anyDir: true},
+		}...)
+	} else {
+		tests = append(tests, []rootCase{
+			{name: "srv", dir: "/srv/Demo", wantErr: true},
+			{name: "srv with override", dir: "/srv/Demo", anyDir: true},
+			{name: "opt", dir: "/opt/Demo", wantErr: true},
+		}...)
+	}
+	// 以符号链接指向常规安装位置之外的目录，按解析后的路径判断
+	link := filepath.Join(apps, "Demo-link")
+	if err := os.Symlink(outside, link); err == nil {
+		tests = append(tests, rootCase{name: "symlink to outside", dir: link, wantErr: true})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkProtectedDir(tt.dir, tt.anyDir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkProtectedDir(%q, %v) error = %v, wantErr %v", tt.dir, tt.anyDir, err, tt.wantErr)
+			}
+		})
+	}
+}
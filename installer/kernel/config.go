@@ -0,0 +1,103 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ConfigFileName stub 在自身所在目录查找的运行时配置文件
+const ConfigFileName = "installer.config.json"
+
+// MetaOverrides 运行时配置文件可覆盖的 meta 字段（键名与 meta.json 相同），未出现的字段保持打包时的值。
+// ProductName、ExeName、Version、Publisher、FileAssociations 等决定安装身份或受签名保护的字段不可覆盖，
+// 出现时视为错误。
+type MetaOverrides struct {
+	InstallDir              *string         `json:"installDir"`
+	InstallScope            *string         `json:"installScope"`
+	CreateDesktopShortcut   *bool           `json:"createDesktopShortcut"`
+	CreateStartMenuShortcut *bool           `json:"createStartMenuShortcut"`
+	ShortcutName            *string         `json:"shortcutName"`
+	Shortcuts               *[]ShortcutSpec `json:"shortcuts"`
+	StartMenuFolder         *string         `json:"startMenuFolder"`
+	Language                *string         `json:"language"`
+	PreserveDirs            *[]string       `json:"preserveDirs"`
+	OverwritePolicy         *string         `json:"overwritePolicy"`
+	StreamingExtract        *bool           `json:"streamingExtract"`
+	CreateRestorePoint      *bool           `json:"createRestorePoint"`
+	LaunchAfterInstall      *bool           `json:"launchAfterInstall"`
+	FinishURL               *string         `json:"finishURL"`
+	FinishReadmeFile        *string         `json:"finishReadmeFile"`
+	LogLevel                *string         `json:"logLevel"`
+	PortableMode            *bool           `json:"portableMode"`
+	SyncWrites              *bool           `json:"syncWrites"`
+}
+
+// ApplyConfig 将 JSON 配置合并到 meta 之上；包含不可覆盖或未知的字段时返回错误且不修改 meta
+func ApplyConfig(meta *InstallMeta, data []byte) error {
+	var o MetaOverrides
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if o.InstallScope != nil && *o.InstallScope != ScopeMachine && *o.InstallScope != ScopeUser {
+		return fmt.Errorf("invalid installScope %q", *o.InstallScope)
+	}
+	if o.LogLevel != nil {
+		if _, err := ParseLogLevel(*o.LogLevel); err != nil {
+			return err
+		}
+	}
+	if o.FinishURL != nil && *o.FinishURL != "" {
+		if err := CheckWebURL(*o.FinishURL); err != nil {
+			return fmt.Errorf("invalid finishURL: %w", err)
+		}
+	}
+	if o.OverwritePolicy != nil {
+		switch *o.OverwritePolicy {
+		case "", OverwriteReplace, OverwriteFail, OverwriteBackup:
+		default:
+			return fmt.Errorf("invalid overwritePolicy %q", *o.OverwritePolicy)
+		}
+	}
+	set(&meta.InstallDir, o.InstallDir)
+	set(&meta.InstallScope, o.InstallScope)
+	set(&meta.CreateDesktopShortcut, o.CreateDesktopShortcut)
+	set(&meta.CreateStartMenuShortcut, o.CreateStartMenuShortcut)
+	set(&meta.ShortcutName, o.ShortcutName)
+	set(&meta.Shortcuts, o.Shortcuts)
+	set(&meta.StartMenuFolder, o.StartMenuFolder)
+	set(&meta.Language, o.Language)
+	set(&meta.PreserveDirs, o.PreserveDirs)
+	set(&meta.OverwritePolicy, o.OverwritePolicy)
+	set(&meta.StreamingExtract, o.StreamingExtract)
+	set(&meta.CreateRestorePoint, o.CreateRestorePoint)
+	set(&meta.LaunchAfterInstall, o.LaunchAfterInstall)
+	set(&meta.FinishURL, o.FinishURL)
+	set(&meta.FinishReadmeFile, o.FinishReadmeFile)
+	set(&meta.LogLevel, o.LogLevel)
+	set(&meta.PortableMode, o.PortableMode)
+	set(&meta.SyncWrites, o.SyncWrites)
+	return nil
+}
+
+func set[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// CheckWebURL 要求 s 是 http / https 网址。FinishURL 会交给 ShellExecute 打开，
+// 不校验时可指向任意本地程序
+func CheckWebURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", s)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package kernel
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestApplyConfigFinishURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://example.com/welcome", false},
+		{"http://example.com", false},
+		{"", false},
+		{`C:\Windows\System32\calc.exe`, true},
+		{"file:///C:/Windows/System32/calc.exe", true},
+		{"calc.exe", true},
+		{"https://", true},
+	}
+	for _, tt := range tests {
+		meta := InstallMeta{FinishURL: "https://packaged.example.com"}
+		err := ApplyConfig(&meta, []byte(`{"finishURL": `+strconv.Quote(tt.url)+`}`))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ApplyConfig(finishURL=%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+		if err != nil && meta.FinishURL != "https://packaged.example.com" {
+			t.Errorf("rejected config still changed FinishURL to %q", meta.FinishURL)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package kernel
+
+import "syscall"
+
+// FreeSpace 返回 dir 所在文件系统上非特权用户可用的字节数
+func FreeSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package kernel
+
+import "golang.org/x/sys/windows"
+
+// FreeSpace 返回 dir 所在卷上当前用户可用的字节数（考虑磁盘配额）
+func FreeSpace(dir string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var avail, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &free); err != nil {
+		return 0, err
+	}
+	return int64(avail), nil
+}
@@ -0,0 +1,44 @@
+package kernel
+
+import "strings"
+
+// FileAssoc 描述一个需要登记的文件类型关联
+type FileAssoc struct {
+	Extension   string `json:"extension"`   // 扩展名，如 ".yuumi"（可省略前导点）
+	ProgID      string `json:"progID"`      // 程序标识，如 "Yuumi.Document"
+	Description string `json:"description"` // 资源管理器中显示的类型说明
+	Icon        string `json:"icon"`        // 图标，绝对路径或相对安装目录；为空则使用主程序图标
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.TrimSpace(ext)
+	if ext == "" {
+		return ""
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return strings.ToLower(ext)
+}
+
+// encodeFileAssocs / decodeFileAssocs 将关联列表与注册表多字符串值（".ext=ProgID"）互转，
+// 供卸载时精确删除安装时写入的键。
+func encodeFileAssocs(assocs []FileAssoc) []string {
+	out := make([]string, 0, len(assocs))
+	for _, a := range assocs {
+		out = append(out, normalizeExt(a.Extension)+"="+a.ProgID)
+	}
+	return out
+}
+
+func decodeFileAssocs(values []string) []FileAssoc {
+	var out []FileAssoc
+	for _, v := range values {
+		ext, progID, ok := strings.Cut(v, "=")
+		if !ok || progID == "" {
+			continue
+		}
+		out = append(out, FileAssoc{Extension: ext, ProgID: progID})
+	}
+	return out
+}
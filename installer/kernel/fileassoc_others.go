@@ -0,0 +1,18 @@
+//go:build !windows
+
+package kernel
+
+// RegisterFileAssociations 在非 Windows 平台为无操作
+func RegisterFileAssociations(assocs []FileAssoc, installDir, exePath string) error {
+	return nil
+}
+
+// UnregisterFileAssociations 在非 Windows 平台为无操作
+func UnregisterFileAssociations(assocs []FileAssoc) error {
+	return nil
+}
+
+// LoadFileAssociations 在非 Windows 平台返回 nil
+func LoadFileAssociations(productName string) []FileAssoc {
+	return nil
+}
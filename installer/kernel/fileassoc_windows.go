@@ -0,0 +1,144 @@
+//go:build windows
+
+package kernel
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const classesRoot = `Software\Classes\`
+
+var procSHChangeNotify = syscall.NewLazyDLL("shell32.dll").NewProc("SHChangeNotify")
+
+// RegisterFileAssociations 在 HKCU\Software\Classes 下为每个扩展名登记 ProgID 与打开命令，
+// 完成后通知 Shell 刷新图标与关联。
+func RegisterFileAssociations(assocs []FileAssoc, installDir, exePath string) error {
+	if len(assocs) == 0 {
+		return nil
+	}
+	var errs []string
+	for _, a := range assocs {
+		if err := registerFileAssociation(a, installDir, exePath); err != nil {
+			errs = append(errs, a.Extension+":"+err.Error())
+		}
+	}
+	notifyAssocChanged()
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func registerFileAssociation(a FileAssoc, installDir, exePath string) error {
+	ext, progID := normalizeExt(a.Extension), a.ProgID
+	if ext == "" || progID == "" {
+		return fmt.Errorf("extension and progID are required")
+	}
+	icon := a.Icon
+	if icon == "" {
+		icon = exePath + ",0"
+	} else if !filepath.IsAbs(icon) {
+		icon = filepath.Join(installDir, icon)
+	}
+
+	if err := setValues(registry.CURRENT_USER, classesRoot+ext, map[string]any{"": progID}); err != nil {
+		return fmt.Errorf("write %s: %w", ext, err)
+	}
+	if err := setValues(registry.CURRENT_USER, classesRoot+progID, map[string]any{"": a.Description}); err != nil {
+		return fmt.Errorf("write %s: %w", progID, err)
+	}
+	if err := setValues(registry.CURRENT_USER, classesRoot+progID+`\DefaultIcon`, map[string]any{"": icon}); err != nil {
+		return fmt.Errorf("write DefaultIcon: %w", err)
+	}
+	command := fmt.Sprintf(`"%s" "%%1"`, exePath)
+	if err := setValues(registry.CURRENT_USER, classesRoot+progID+`\shell\open\command`, map[string]any{"": command}); err != nil {
+		return fmt.Errorf("write open command: %w", err)
+	}
+	return nil
+}
+
+// UnregisterFileAssociations 删除 RegisterFileAssociations 写入的键；
+// 扩展名键仅在其默认值仍指向我们的 ProgID 时删除，避免破坏其他程序后来设置的关联。
+func UnregisterFileAssociations(assocs []FileAssoc) error {
+	if len(assocs) == 0 {
+		return nil
+	}
+	var errs []string
+	for _, a := range assocs {
+		ext := normalizeExt(a.Extension)
+		if ext != "" {
+			if k, err := registry.OpenKey(registry.CURRENT_USER, classesRoot+ext, registry.QUERY_VALUE); err == nil {
+				v, _, _ := k.GetStringValue("")
+				k.Close()
+				if v == a.ProgID {
+					if err := deleteKeyTree(registry.CURRENT_USER, classesRoot+ext); err != nil {
+						errs = append(errs, ext+":"+err.Error())
+					}
+				}
+			}
+		}
+		if a.ProgID != "" {
+			if err := deleteKeyTree(registry.CURRENT_USER, classesRoot+a.ProgID); err != nil {
+				errs = append(errs, a.ProgID+":"+err.Error())
+			}
+		}
+	}
+	notifyAssocChanged()
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// LoadFileAssociations 读取安装时记录在 Software\<ProductName> 基础键中的关联列表
+func LoadFileAssociations(productName string) []FileAssoc {
+	root := registryRoot(InstalledPerMachine(productName))
+	k, err := registry.OpenKey(root, `Software\\`+productName, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer k.Close()
+	values, _, err := k.GetStringsValue("FileAssociations")
+	if err != nil {
+		return nil
+	}
+	return decodeFileAssocs(values)
+}
+
+// deleteKeyTree 递归删除注册表键（registry.DeleteKey 不能删除含子键的键）；键不存在视为成功
+func deleteKeyTree(root registry.Key, path string) error {
+	k, err := registry.OpenKey(root, path, registry.ENUMERATE_SUB_KEYS)
+	if err == registry.ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	subs, err := k.ReadSubKeyNames(-1)
+	k.Close()
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := deleteKeyTree(root, path+`\`+sub); err != nil {
+			return err
+		}
+	}
+	if err := registry.DeleteKey(root, path); err != nil && err != registry.ErrNotExist {
+		return err
+	}
+	return nil
+}
+
+func notifyAssocChanged() {
+	const (
+		shcneAssocChanged = 0x08000000
+		shcnfIDList       = 0x0000
+	)
+	_, _, _ = procSHChangeNotify.Call(shcneAssocChanged, shcnfIDList, 0, 0)
+}
@@ -0,0 +1,313 @@
+package kernel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 内置语言代码；未匹配到的语言回退到 en-US
+const (
+	LangZhCN     = "zh-CN"
+	LangEnUS     = "en-US"
+	fallbackLang = LangEnUS
+)
+
+// catalogs 保存各语言的消息表：语言代码 -> 消息键 -> 格式串（fmt 语法）。
+// 新增语言：在此处添加一份完整的表，或在打包时通过 Options.Messages 写入 meta.json，
+// 运行时由 RegisterMessages 合并。缺失的键会回退到 en-US。
+var catalogs = map[string]map[string]string{
+	LangZhCN: {
+		"installing":             "正在安装，请稍候...",
+		"extractSelfFailed":      "无法提取内置归档: %v",
+		"unpacking":              "正在解压归档...",
+		"unpackFailed":           "解包归档失败: %v",
+		"unpacked":               "解压完成，共 %d 个条目。",
+		"product":                "产品: %s  版本: %s",
+		"mkInstallDirFailed":     "创建安装目录失败: %v",
+		"installDir":             "目标安装目录: %s",
+		"cleaning":               "清理旧版本文件（若存在）...",
+		"cleanFailed":            "清理已有目录失败: %v",
+		"confirmClean":           "目录 %s 中已有 %d 个文件，继续安装将全部删除。是否继续？[y/N] ",
+		"cleanAborted":           "已取消安装，未删除任何文件。",
+		"cleanNeedsForce":        "安装目录 %s 非空（%d 个文件），静默模式下需要 --force 才会清空，安装中止。",
+		"cleaned":                "目录清理完成，开始写入文件...",
+		"writeFailed":            "写文件失败: %v",
+		"written":                "文件写入完成。",
+		"installedTo":            "已安装到: %s",
+		"exeNotFound":            "未找到指定主程序 %s，尝试自动查找...",
+		"exeDetected":            "自动发现可执行文件: %s",
+		"noExe":                  "安装包中没有主程序 %s，也没有其他 .exe，安装包可能不完整或已损坏。",
+		"creatingShortcuts":      "开始创建快捷方式...",
+		"shortcutsFailed":        "创建快捷方式失败（忽略）：%v",
+		"shortcutsCreated":       "快捷方式创建完成。",
+		"uninstallerFailed":      "创建卸载程序失败（忽略）：%v",
+		"registryFailed":         "写入注册表失败（忽略）：%v",
+		"registryWritten":        "已写入注册表信息并回读校验通过。",
+		"installDone":            "安装完成，祝您使用愉快！",
+		"pressEnter":             "按回车退出...",
+		"uninstalling":           "正在卸载...",
+		"selfDeleteFailed":       "自删除计划失败（手动删除目录）：%v",
+		"selfDeleteScheduled":    "已计划删除卸载程序与安装目录...",
+		"uninstallDone":          "卸载完成。",
+		"mkdirLog":               "[%d/%d] 创建目录: %s",
+		"writeLog":               "[%d/%d] 写入文件: %s (%d bytes)",
+		"desktopShortcut":        " - 正在创建桌面快捷方式...",
+		"desktopShortcutFail":    "   × 桌面快捷方式失败: %v",
+		"desktopShortcutOK":      "   √ 桌面快捷方式: %s",
+		"startMenuShortcut":      " - 正在创建开始菜单快捷方式...",
+		"startMenuShortcutErr":   "   × 开始菜单快捷方式失败: %v",
+		"startMenuShortcutOK":    "   √ 开始菜单快捷方式: %s",
+		"fileAssocFailed":        "登记文件关联失败（忽略）：%v",
+		"fileAssocRegistered":    "已登记 %d 个文件关联。",
+		"chooseScope":            "请选择安装范围：1) 所有用户（需要管理员权限）  2) 仅当前用户  [默认 %d]: ",
+		"elevating":              "正在请求管理员权限...",
+		"elevationFailed":        "未能获得管理员权限，无法为所有用户安装/卸载。",
+		"elevateError":           "请求管理员权限失败: %v",
+		"fileInUse":              "请先关闭正在运行的 %s（%s 正在使用中）。",
+		"retryOrCancel":          "关闭后按 R 重试，按 C 取消安装 [R/c] ",
+		"manifestFailed":         "写入安装清单失败（将无法修复）: %v",
+		"repairing":              "正在校验 %d 个文件...",
+		"repairFailed":           "修复失败: %v",
+		"repairDone":             "修复完成，共修复 %d 个文件。",
+		"keepUserData":           "是否保留用户数据（安装后新建的文件及数据目录）？[Y/n] ",
+		"removeFilesFailed":      "部分文件删除失败: %v",
+		"signatureInvalid":       "安装程序签名校验失败，文件可能已被篡改，已拒绝安装: %v",
+		"details":                "详细信息:\n  文件: %d 个，共 %s\n  发布者: %s\n  归档 SHA-256: %s",
+		"uninstallShortcut":      "卸载 %s",
+		"launchFailed":           "启动程序失败: %v",
+		"launched":               "已启动: %s",
+		"invalidExeName":         "安装包中的程序名无效，已拒绝安装: %v",
+		"openReadme":             "是否查看说明文档 %s？[y/N] ",
+		"openURL":                "是否访问官网 %s？[y/N] ",
+		"openFailed":             "打开失败: %v",
+		"restorePointCreated":    "已创建系统还原点。",
+		"restorePointSkipped":    "未创建系统还原点（继续安装）: %v",
+		"restorePointNeedsAdmin": "需要管理员权限",
+		"streamingMode":          "低内存模式：将边解压边写入。",
+		"configFailed":           "读取配置文件 %s 失败: %v",
+		"configApplied":          "已应用配置文件: %s",
+		"dirNotWritable":         "安装目录不可写，请选择其他目录或以管理员身份运行: %s",
+		"diskSpace":              "需要约 %s 磁盘空间，可用 %s",
+		"diskSpaceLow":           "警告：可用磁盘空间可能不足，安装可能失败",
+		"metaMissing":            "警告：安装包中缺少 meta.json，将使用默认设置（产品名、主程序等可能不正确）",
+		"logFileFailed":          "无法打开日志文件: %v",
+		"installerCorrupted":     "安装程序文件已损坏，请重新下载",
+		"chooseShortcut":         "创建快捷方式“%s”（%s）？[Y/n] ",
+		"locDesktop":             "桌面",
+		"locStartMenu":           "开始菜单",
+		"confirmOverwrite":       "目录 %s 中已有 %d 个同名文件（如 %s）将被覆盖，其他文件保留。是否继续？[y/N] ",
+		"overwriteNeedsForce":    "安装目录 %s 中有 %d 个文件将被覆盖，静默模式下需要 --force，安装中止。",
+		"portableMode":           "便携模式：只解压文件，不创建卸载程序、快捷方式与注册表项",
+		"verifying":              "正在运行安装验证...",
+		"verified":               "安装验证通过",
+		"verifyFailed":           "安装验证失败: %v",
+		"rollingBack":            "正在回滚本次安装...",
+		"serviceNeedsMachine":    "服务 %s 需要为全部用户安装",
+		"serviceStopFailed":      "停止服务失败: %v",
+		"serviceFailed":          "登记服务 %s 失败: %v",
+		"serviceInstalled":       "已登记服务 %s。",
+		"platformMismatch":       "此安装程序需要 %s（当前为 %s）",
+		"upgradeDiff":            "已安装版本 %s，本次更新将: 新增 %d, 替换 %d, 删除 %d",
+		"upgradeObsoleteKept":    "以下 %d 个旧版本文件不在新版本中，将保留在安装目录:",
+		"uninstallIncomplete":    "卸载未完全完成: %v",
+		"uninstallCancelHint":    "按 Ctrl+C 可停止卸载。",
+		"uninstallCancelled":     "卸载已取消，部分文件已删除，%s 可能无法正常使用。再次运行卸载程序可继续卸载。",
+		"removeLog":              "[%d/%d] 已删除: %s",
+		"templateFailed":         "生成配置文件失败: %v",
+		"templatesRendered":      "已根据模板生成 %d 个配置文件。",
+		"fileAttrsFailed":        "设置文件属性失败（忽略）：%v",
+		"registryFailedElevated": "写入注册表失败：%v。程序不会出现在“设置 > 应用”中，请使用 %s 卸载",
+		"registryVerifyFailed":   "注册表回读校验失败：%v。程序可能无法从“设置 > 应用”中卸载，请使用 %s 卸载",
+		"manifestEntrySkipped":   "安装清单中的条目 %q 不在安装目录内，已跳过",
+		"shortcutSkipped":        "安装清单中的快捷方式 %s 不在开始菜单或桌面中，已跳过",
+		"elevatedChildFailed":    "以管理员身份运行的进程以退出码 %d 结束，详见其窗口中的输出。",
+	},
+	LangEnUS: {
+		"installing":             "Installing, please wait...",
+		"extractSelfFailed":      "Failed to extract the embedded archive: %v",
+		"unpacking":              "Unpacking archive...",
+		"unpackFailed":           "Failed to unpack archive: %v",
+		"unpacked":               "Unpacked %d entries.",
+		"product":                "Product: %s  Version: %s",
+		"mkInstallDirFailed":     "Failed to create install directory: %v",
+		"installDir":             "Install directory: %s",
+		"cleaning":               "Removing files from a previous version (if any)...",
+		"cleanFailed":            "Failed to clean existing directory: %v",
+		"confirmClean":           "%s already contains %d files which will all be deleted. Continue? [y/N] ",
+		"cleanAborted":           "Installation cancelled, no files were deleted.",
+		"cleanNeedsForce":        "Install directory %s is not empty (%d files); silent mode requires --force to clear it. Aborting.",
+		"cleaned":                "Directory cleaned, writing files...",
+		"writeFailed":            "Failed to write files: %v",
+		"written":                "Files written.",
+		"installedTo":            "Installed to: %s",
+		"exeNotFound":            "Main program %s not found, searching for one...",
+		"exeDetected":            "Found executable: %s",
+		"noExe":                  "The package contains neither the main program %s nor any other .exe; it may be incomplete or corrupted.",
+		"creatingShortcuts":      "Creating shortcuts...",
+		"shortcutsFailed":        "Failed to create shortcuts (ignored): %v",
+		"shortcutsCreated":       "Shortcuts created.",
+		"uninstallerFailed":      "Failed to create uninstaller (ignored): %v",
+		"registryFailed":         "Failed to write registry (ignored): %v",
+		"registryWritten":        "Registry entries written and verified.",
+		"installDone":            "Installation complete. Enjoy!",
+		"pressEnter":             "Press Enter to exit...",
+		"uninstalling":           "Uninstalling...",
+		"selfDeleteFailed":       "Failed to schedule self-deletion (remove the directory manually): %v",
+		"selfDeleteScheduled":    "Scheduled removal of the uninstaller and install directory...",
+		"uninstallDone":          "Uninstall complete.",
+		"mkdirLog":               "[%d/%d] Created directory: %s",
+		"writeLog":               "[%d/%d] Wrote file: %s (%d bytes)",
+		"desktopShortcut":        " - Creating desktop shortcut...",
+		"desktopShortcutFail":    "   × Desktop shortcut failed: %v",
+		"desktopShortcutOK":      "   √ Desktop shortcut: %s",
+		"startMenuShortcut":      " - Creating Start Menu shortcut...",
+		"startMenuShortcutErr":   "   × Start Menu shortcut failed: %v",
+		"startMenuShortcutOK":    "   √ Start Menu shortcut: %s",
+		"fileAssocFailed":        "Failed to register file associations (ignored): %v",
+		"fileAssocRegistered":    "Registered %d file associations.",
+		"chooseScope":            "Install for: 1) all users (requires administrator)  2) current user only  [default %d]: ",
+		"elevating":              "Requesting administrator privileges...",
+		"elevationFailed":        "Administrator privileges were not granted; cannot install/uninstall for all users.",
+		"elevateError":           "Failed to request administrator privileges: %v",
+		"fileInUse":              "Please close the running %s first (%s is in use).",
+		"retryOrCancel":          "Press R to retry after closing it, or C to cancel [R/c] ",
+		"manifestFailed":         "Failed to write install manifest (repair will be unavailable): %v",
+		"repairing":              "Verifying %d files...",
+		"repairFailed":           "Repair failed: %v",
+		"repairDone":             "Repair complete, %d files repaired.",
+		"keepUserData":           "Keep user data (files created after installation and data folders)? [Y/n] ",
+		"removeFilesFailed":      "Some files could not be removed: %v",
+		"signatureInvalid":       "Installer signature verification failed; the file may have been tampered with. Refusing to install: %v",
+		"details":                "Details:\n  Files: %d, %s in total\n  Publisher: %s\n  Archive SHA-256: %s",
+		"uninstallShortcut":      "Uninstall %s",
+		"launchFailed":           "Failed to launch the program: %v",
+		"launched":               "Launched: %s",
+		"invalidExeName":         "Invalid program name in the installer package, refusing to install: %v",
+		"openReadme":             "View the documentation %s? [y/N] ",
+		"openURL":                "Visit the website %s? [y/N] ",
+		"openFailed":             "Failed to open: %v",
+		"restorePointCreated":    "System restore point created.",
+		"restorePointSkipped":    "System restore point not created (continuing): %v",
+		"restorePointNeedsAdmin": "administrator rights required",
+		"streamingMode":          "Low-memory mode: files will be extracted while writing.",
+		"configFailed":           "Failed to read config file %s: %v",
+		"configApplied":          "Applied config file: %s",
+		"dirNotWritable":         "The install directory is not writable. Choose another directory or run as administrator: %s",
+		"diskSpace":              "Requires about %s of disk space, %s available",
+		"diskSpaceLow":           "Warning: there may not be enough free disk space; the installation may fail",
+		"metaMissing":            "Warning: meta.json is missing from the package; using defaults (product name, main program, etc. may be wrong)",
+		"logFileFailed":          "Cannot open log file: %v",
+		"installerCorrupted":     "The installer file is corrupted. Please download it again.",
+		"chooseShortcut":         "Create shortcut \"%s\" (%s)? [Y/n] ",
+		"locDesktop":             "Desktop",
+		"locStartMenu":           "Start menu",
+		"confirmOverwrite":       "%s already contains %d files that will be overwritten (e.g. %s); other files are kept. Continue? [y/N] ",
+		"overwriteNeedsForce":    "%[2]d files in %[1]s would be overwritten; silent mode requires --force. Aborting.",
+		"portableMode":           "Portable mode: extracting files only; no uninstaller, shortcuts or registry entries",
+		"verifying":              "Running installation verification...",
+		"verified":               "Installation verified.",
+		"verifyFailed":           "Installation verification failed: %v",
+		"rollingBack":            "Rolling back this installation...",
+		"serviceNeedsMachine":    "Service %s requires an all-users installation",
+		"serviceStopFailed":      "Failed to stop service: %v",
+		"serviceFailed":          "Failed to register service %s: %v",
+		"serviceInstalled":       "Service %s registered.",
+		"platformMismatch":       "This installer requires %s (this system is %s)",
+		"upgradeDiff":            "Installed version %s; this update will add %d, replace %d and remove %d file(s)",
+		"upgradeObsoleteKept":    "The following %d file(s) from the old version are not in the new version and will be kept:",
+		"uninstallIncomplete":    "Uninstall did not complete cleanly: %v",
+		"uninstallCancelHint":    "Press Ctrl+C to stop uninstalling.",
+		"uninstallCancelled":     "Uninstall cancelled. Some files were removed and %s may no longer work. Run the uninstaller again to finish.",
+		"removeLog":              "[%d/%d] Removed: %s",
+		"templateFailed":         "Failed to generate configuration files: %v",
+		"templatesRendered":      "Generated %d file(s) from templates.",
+		"fileAttrsFailed":        "Failed to set file attributes (ignored): %v",
+		"registryFailedElevated": "Failed to write registry: %v. The app will not appear in Settings > Apps; uninstall it with %s",
+		"registryVerifyFailed":   "Registry read-back check failed: %v. The app may not be uninstallable from Settings > Apps; use %s instead",
+		"manifestEntrySkipped":   "Skipped install manifest entry %q outside the install directory",
+		"shortcutSkipped":        "Skipped shortcut %s from the install manifest: not in the Start Menu or on the desktop",
+		"elevatedChildFailed":    "The elevated process exited with code %d; see its window for details.",
+	},
+}
+
+var currentLang = fallbackLang
+
+// SetLanguage 切换当前语言，lang 可为 "zh-CN"、"zh_CN.UTF-8"、"en" 等形式；
+// 无法匹配任何已知语言时使用 en-US。
+func SetLanguage(lang string) {
+	currentLang = matchLanguage(lang)
+}
+
+// Language 返回当前生效的语言代码
+func Language() string { return currentLang }
+
+// RegisterMessages 新增或覆盖某种语言的消息（按键合并）
+func RegisterMessages(lang string, msgs map[string]string) {
+	lang = normalizeLanguage(lang)
+	if lang == "" {
+		return
+	}
+	cat, ok := catalogs[lang]
+	if !ok {
+		cat = map[string]string{}
+		catalogs[lang] = cat
+	}
+	for k, v := range msgs {
+		cat[k] = v
+	}
+}
+
+// T 按当前语言格式化消息；当前语言缺失该键时回退到 en-US，仍缺失则返回键名
+func T(key string, args ...any) string {
+	format, ok := catalogs[currentLang][key]
+	if !ok {
+		if format, ok = catalogs[fallbackLang][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// normalizeLanguage 将 "zh_CN.UTF-8"、"zh-cn" 等写法统一为 "zh-CN"
+func normalizeLanguage(lang string) string {
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	lang = strings.ReplaceAll(strings.TrimSpace(lang), "_", "-")
+	if lang == "" || lang == "C" || lang == "POSIX" {
+		return ""
+	}
+	parts := strings.SplitN(lang, "-", 2)
+	parts[0] = strings.ToLower(parts[0])
+	if len(parts) == 2 {
+		parts[1] = strings.ToUpper(parts[1])
+	}
+	return strings.Join(parts, "-")
+}
+
+// matchLanguage 先精确匹配，再按主语言（如 "zh"）匹配已知目录
+func matchLanguage(lang string) string {
+	lang = normalizeLanguage(lang)
+	if lang == "" {
+		return fallbackLang
+	}
+	if _, ok := catalogs[lang]; ok {
+		return lang
+	}
+	primary := strings.SplitN(lang, "-", 2)[0]
+	known := make([]string, 0, len(catalogs))
+	for k := range catalogs {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+	for _, k := range known {
+		if k == primary || strings.HasPrefix(k, primary+"-") {
+			return k
+		}
+	}
+	return fallbackLang
+}
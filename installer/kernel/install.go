@@ -0,0 +1,621 @@
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// InstallMeta 与打包时的 meta.json 对应
+type InstallMeta struct {
+	ProductName             string `json:"productName"`
+	ExeName                 string `json:"exeName"`
+	InstallDir              string `json:"installDir"`
+	CreateDesktopShortcut   bool   `json:"createDesktopShortcut"`
+	CreateStartMenuShortcut bool   `json:"createStartMenuShortcut"`
+	Version                 string `json:"version"`
+	GeneratedAt             string `json:"generatedAt"`
+	ShortcutName            string `json:"shortcutName"`
+	// Publisher 发布者，显示在“应用和功能”中
+	Publisher string `json:"publisher,omitempty"`
+	// InstallScope 安装范围：ScopeMachine（全部用户，默认）或 ScopeUser（仅当前用户）
+	InstallScope string `json:"installScope,omitempty"`
+	// FileAssociations 需要登记的文件类型关联（仅 Windows）
+	FileAssociations []FileAssoc `json:"fileAssociations,omitempty"`
+	// FileAttributes 路径模式 -> 文件属性（AttrReadOnly / AttrHidden，可用逗号组合），见 ApplyFileAttributes
+	FileAttributes map[string]string `json:"fileAttributes,omitempty"`
+	// TemplateFiles 安装后按 RenderTemplates 渲染的文件（归档内路径模式，如 "conf/*.template"）
+	TemplateFiles []string `json:"templateFiles,omitempty"`
+	// KeepInstallDir 卸载时只删除安装的文件，保留安装目录本身（记录在安装清单中，见 UninstallOptions.RemoveInstallDir）
+	KeepInstallDir bool `json:"keepInstallDir,omitempty"`
+	// AllowAnyInstallDir 允许清空与卸载常规安装位置之外的目录（见 cleanguard.go，记录在安装清单中）
+	AllowAnyInstallDir bool `json:"allowAnyInstallDir,omitempty"`
+	// WriteWorkers 并发写入文件的协程数，0 为默认值 min(4, CPU 数)，1 为顺序写入
+	WriteWorkers int `json:"writeWorkers,omitempty"`
+	// PlatformDirs 归档内按平台区分的目录 -> 平台（"goos" 或 "goos/goarch"），见 PlatformSelector
+	PlatformDirs map[string]string `json:"platformDirs,omitempty"`
+	// RequiredOS / RequiredArch 允许的系统与架构（GOOS / GOARCH 命名，如 "windows"、"amd64"），为空表示不限
+	RequiredOS   []string `json:"requiredOS,omitempty"`
+	RequiredArch []string `json:"requiredArch,omitempty"`
+	// ServiceName 非空时安装后登记为 Windows 服务（需全部用户安装），卸载时停止并删除
+	ServiceName        string `json:"serviceName,omitempty"`
+	ServiceDisplayName string `json:"serviceDisplayName,omitempty"` // 服务显示名称，为空时同 ServiceName
+	ServiceExePath     string `json:"serviceExePath,omitempty"`     // 服务程序，相对安装目录；为空时使用主程序
+	ServiceStartType   string `json:"serviceStartType,omitempty"`   // ServiceStartAuto / ServiceStartManual（默认）/ ServiceStartDisabled
+	// Language 界面语言（如 "zh-CN"、"en-US"），为空时跟随系统区域设置
+	Language string `json:"language,omitempty"`
+	// Messages 打包时附带的额外消息表：语言代码 -> 消息键 -> 文本，用于新增语言或覆盖内置文案
+	Messages map[string]map[string]string `json:"messages,omitempty"`
+	// Shortcuts 需要创建的快捷方式；为空时按 CreateDesktopShortcut / CreateStartMenuShortcut 生成
+	Shortcuts []ShortcutSpec `json:"shortcuts,omitempty"`
+	// StartMenuFolder 开始菜单中的程序文件夹名称，为空时使用 ProductName
+	StartMenuFolder string `json:"startMenuFolder,omitempty"`
+	// StreamingExtract 低内存模式：不把归档解到内存，边解压边写入磁盘
+	StreamingExtract bool `json:"streamingExtract,omitempty"`
+	// OverwritePolicy 目标文件已存在时的处理方式：OverwriteReplace（默认，安装前清空目录）、
+	// OverwriteFail 或 OverwriteBackup（这两种不清空目录）
+	OverwritePolicy string `json:"overwritePolicy,omitempty"`
+	// CreateRestorePoint 写入文件前创建系统还原点（仅 Windows 且已提权时）
+	CreateRestorePoint bool `json:"createRestorePoint,omitempty"`
+	// FinishURL 安装完成后询问是否打开的网址（如官网）
+	FinishURL string `json:"finishURL,omitempty"`
+	// FinishReadmeFile 安装完成后询问是否打开的说明文档（相对安装目录）
+	FinishReadmeFile string `json:"finishReadmeFile,omitempty"`
+	// LaunchAfterInstall 安装成功后自动启动主程序（命令行 --launch 同效）
+	LaunchAfterInstall bool `json:"launchAfterInstall,omitempty"`
+	// PreserveDirs 卸载时始终保留的目录（相对安装目录，如 "data"、"saves"）
+	PreserveDirs []string `json:"preserveDirs,omitempty"`
+	// PreserveTimestamps 安装的文件保留打包时源文件的修改时间（默认为安装时间）
+	PreserveTimestamps bool `json:"preserveTimestamps,omitempty"`
+	// SyncWrites 每个文件写入后 fsync，全部写完后再同步目录，防止断电后留下空文件或不完整的文件（较慢）
+	SyncWrites bool `json:"syncWrites,omitempty"`
+	// CleanBeforeInstall 覆盖策略下安装前是否清空安装目录，nil 视为 true；
+	// false 时直接覆盖写入同名文件，其他文件保留（如向已有程序目录中安装插件）
+	CleanBeforeInstall *bool `json:"cleanBeforeInstall,omitempty"`
+	// PortableMode 便携模式：只解压文件，不生成卸载程序、安装清单、快捷方式与注册表项
+	PortableMode bool `json:"portableMode,omitempty"`
+	// VerifyCmd 安装完成后运行的验证命令（见 RunVerifyCmd），失败时回滚本次安装
+	VerifyCmd string `json:"verifyCmd,omitempty"`
+	// LogLevel 控制台日志级别："debug"、"info"（默认）、"warn" 或 "error"
+	LogLevel string `json:"logLevel,omitempty"`
+}
+
+// 安装范围
+const (
+	ScopeMachine = "machine" // Program Files + HKLM，需要管理员权限
+	ScopeUser    = "user"    // %LOCALAPPDATA%\Programs + HKCU，无需提权
+)
+
+// PerMachine 报告是否为全部用户安装（InstallScope 为空时按全部用户处理）
+func (m InstallMeta) PerMachine() bool {
+	return m.InstallScope != ScopeUser
+}
+
+// DefaultMeta 返回 meta.json 缺失时使用的默认值
+func DefaultMeta() InstallMeta {
+	return InstallMeta{
+		ProductName:             "MyApp",
+		ExeName:                 "app.exe",
+		InstallDir:              "",
+		CreateDesktopShortcut:   true,
+		CreateStartMenuShortcut: true,
+		ShortcutName:            "", // 为空表示使用 ProductName
+	}
+}
+
+// ParseMeta 从归档条目中解析 meta.json 覆盖到 meta 上（宽松处理，缺失或损坏时保持原值），
+// 返回是否找到 meta.json
+func ParseMeta(files []*InMemoryFile, meta *InstallMeta) bool {
+	m := FindFile(files, "meta.json")
+	if m == nil {
+		return false
+	}
+	_ = json.Unmarshal(m.Data, meta)
+	return true
+}
+
+// ApplyLanguage 注册 meta 携带的消息表，并按 meta.Language（为空时跟随系统）切换语言
+func ApplyLanguage(meta InstallMeta) {
+	for lang, msgs := range meta.Messages {
+		RegisterMessages(lang, msgs)
+	}
+	lang := meta.Language
+	if lang == "" {
+		lang = DetectLanguage()
+	}
+	SetLanguage(lang)
+}
+
+// InstallFromArchive 无界面地将 tar.gz 归档安装到 targetDir（为空时按 meta 推断），
+// 依次完成解包、清理旧文件、写入文件、创建快捷方式与写入注册表。
+// 与 stub 不同，它不会生成 uninstall.exe（调用方自身并不是安装器），因此除非归档中自带 uninstall.exe，
+// 不会在“应用和功能”中登记卸载入口；卸载可调用 Uninstall。
+func InstallFromArchive(archive []byte, targetDir string, meta InstallMeta) error {
+	var files []*InMemoryFile
+	var err error
+	limits := DefaultArchiveLimits
+	limits.Select = meta.PlatformSelector()
+	if !meta.StreamingExtract {
+		if files, err = UntarGzToMemory(archive, limits); err != nil {
+			return fmt.Errorf("untar archive: %w", err)
+		}
+	}
+
+	if targetDir == "" {
+		targetDir = meta.InstallDir
+	}
+	if meta.ExeName, err = LocalPath(meta.ExeName); err != nil {
+		return fmt.Errorf("invalid ExeName: %w", err)
+	}
+	installDir, err := DecideInstallDir(meta.ProductName, targetDir, meta.PerMachine() && !meta.PortableMode)
+	if err != nil {
+		return fmt.Errorf("create install dir: %w", err)
+	}
+	if err := CheckWritable(installDir); err != nil {
+		return fmt.Errorf("install dir not writable: %w", err)
+	}
+	// 只有覆盖策略且未关闭 CleanBeforeInstall 时才清空旧内容；fail / backup 需要看到已有文件
+	if meta.ShouldClean() {
+		if err := CleanInstallDir(installDir, meta, PreservedPaths(installDir, meta)...); err != nil {
+			return fmt.Errorf("clean install dir: %w", err)
+		}
+	}
+	var manifest Manifest
+	if meta.StreamingExtract {
+		entries, err := StreamToDir(archive, installDir, limits, meta.WriteOptions(nil))
+		if err != nil {
+			return fmt.Errorf("write files: %w", err)
+		}
+		manifest = ManifestFor(meta, entries)
+	} else {
+		if err := WriteFiles(files, installDir, meta.WriteOptions(nil)); err != nil {
+			return fmt.Errorf("write files: %w", err)
+		}
+		manifest = BuildManifest(meta, files)
+	}
+
+	exePath := meta.ExePath(installDir)
+	if _, err := os.Stat(exePath); err != nil {
+		if exePath = DetectAnyExe(installDir); exePath == "" {
+			return fmt.Errorf("exe %s not found in archive", meta.ExeName)
+		}
+	}
+	if _, err := RenderTemplates(installDir, exePath, meta, &manifest); err != nil {
+		return err
+	}
+	if _, err := ApplyFileAttributes(installDir, meta, manifest); err != nil {
+		return err
+	}
+	// 便携模式只解压文件
+	if meta.PortableMode {
+		return nil
+	}
+
+	created, err := CreateShortcuts(meta, installDir, exePath)
+	manifest.Shortcuts, manifest.StartMenuFolder = created.Links, created.StartMenuFolder
+	if err := WriteManifest(installDir, manifest); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("create shortcuts: %w", err)
+	}
+	if err := WriteRegistry(meta, installDir, exePath, manifest.InstalledSize(installDir)); err != nil {
+		return fmt.Errorf("write registry: %w", err)
+	}
+	if err := RegisterFileAssociations(meta.FileAssociations, installDir, exePath); err != nil {
+		return fmt.Errorf("register file associations: %w", err)
+	}
+	if err := InstallService(meta, installDir, exePath); err != nil {
+		return fmt.Errorf("install service: %w", err)
+	}
+	return nil
+}
+
+// 目标文件已存在时的处理方式
+const (
+	OverwriteReplace = "overwrite" // 直接覆盖（默认）
+	OverwriteFail    = "fail"      // 返回错误，不改动已有文件
+	OverwriteBackup  = "backup"    // 先将已有文件重命名为 <name>.bak 再写入
+)
+
+// WriteOptions 控制 WriteFiles 的行为
+type WriteOptions struct {
+	Progress  *Progress // 逐条上报进度，可为 nil
+	Overwrite string    // 目标文件已存在时的处理方式，空值等同 OverwriteReplace
+	// PreserveTimestamps 写入后将文件修改时间恢复为归档中记录的时间
+	PreserveTimestamps bool
+	// Workers WriteFiles 并发写入文件的协程数，<=0 时为 DefaultWriteWorkers()，1 为逐个顺序写入；
+	// 流式写入（StreamToDir）总是顺序进行
+	Workers int
+	// Sync 每个文件写入后刷到磁盘（fsync），全部写完后同步目标目录树中的目录
+	Sync bool
+}
+
+// WriteOptions 返回按 meta 设置的写入选项
+func (m InstallMeta) WriteOptions(progress *Progress) WriteOptions {
+	return WriteOptions{Progress: progress, Overwrite: m.Overwrite(), PreserveTimestamps: m.PreserveTimestamps, Workers: m.WriteWorkers, Sync: m.SyncWrites}
+}
+
+// ShouldClean 报告安装前是否需要清空安装目录
+func (m InstallMeta) ShouldClean() bool {
+	return m.Overwrite() == OverwriteReplace && (m.CleanBeforeInstall == nil || *m.CleanBeforeInstall)
+}
+
+// ConflictingFiles 返回 files 中在 dir 下已存在的文件（归档内路径），即不清空目录直接写入时会被覆盖的文件
+func ConflictingFiles(files []*InMemoryFile, dir string) []string {
+	var out []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(f.Name))); err == nil {
+			out = append(out, f.Name)
+		}
+	}
+	return out
+}
+
+// Overwrite 返回 meta 的覆盖策略，未设置时为 OverwriteReplace
+func (m InstallMeta) Overwrite() string {
+	if m.OverwritePolicy == "" {
+		return OverwriteReplace
+	}
+	return m.OverwritePolicy
+}
+
+// WriteFiles 将条目写入 base 目录
+func WriteFiles(files []*InMemoryFile, base string, opts WriteOptions) error {
+	progress := opts.Progress
+	var total int64
+	for _, f := range files {
+		total += int64(len(f.Data))
+	}
+	// fail 策略先检查全部目标，避免写入一半才发现冲突
+	if opts.Overwrite == OverwriteFail {
+		for _, f := range files {
+			if strings.HasSuffix(f.Name, "/") {
+				continue
+			}
+			if err := prepareDest(filepath.Join(base, f.Name), OverwriteFail); err != nil {
+				return err
+			}
+		}
+	}
+	progress.StartPhase(PhaseWrite, total, len(files))
+
+	// 先按顺序创建全部目录（含各文件的上级目录），此后各文件的写入互不依赖，可以并发
+	var regular []*InMemoryFile
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			dir := filepath.Join(base, strings.TrimSuffix(f.Name, "/"))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			progress.AddItem(dir, 0, true)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(base, f.Name)), 0o755); err != nil {
+			return err
+		}
+		regular = append(regular, f)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWriteWorkers()
+	}
+	workers = min(workers, len(regular))
+	// mu 保护 firstErr，并让进度回调逐个执行，订阅者无需自行加锁
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan *InMemoryFile)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				dest := filepath.Join(base, f.Name)
+				err := writeFile(f, dest, opts)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				} else if err == nil {
+					progress.AddItem(dest, int64(len(f.Data)), false)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range regular {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr == nil && opts.Sync {
+		firstErr = syncDirs(base)
+	}
+	return firstErr
+}
+
+// DefaultWriteWorkers 未指定 WriteWorkers 时并发写入的协程数：min(4, CPU 数)
+func DefaultWriteWorkers() int {
+	return min(4, runtime.NumCPU())
+}
+
+// writeFile 按覆盖策略写入单个文件，上级目录须已存在
+func writeFile(f *InMemoryFile, dest string, opts WriteOptions) error {
+	if err := prepareDest(dest, opts.Overwrite); err != nil {
+		return err
+	}
+	mode := os.FileMode(f.Mode)
+	if mode == 0 {
+		mode = 0o644
+	}
+	// Windows 下执行位不会实际影响 exe，可保留
+	if err := writeData(dest, f.Data, mode, opts.Sync); err != nil {
+		return err
+	}
+	if opts.PreserveTimestamps && !f.ModTime.IsZero() {
+		return os.Chtimes(dest, f.ModTime, f.ModTime)
+	}
+	return nil
+}
+
+// writeData 同 os.WriteFile；sync 为 true 时关闭前 fsync
+func writeData(dest string, data []byte, mode os.FileMode, sync bool) error {
+	if !sync {
+		return os.WriteFile(dest, data, mode)
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDirs fsync dir 及其下的所有目录，使新建文件的目录项落盘。
+// Windows 不支持同步目录句柄（NTFS 元数据由日志保证），直接返回
+func syncDirs(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		err = f.Sync()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("sync %s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// prepareDest 按覆盖策略处理已存在的目标文件
+func prepareDest(dest, policy string) error {
+	if _, err := os.Lstat(dest); err != nil {
+		return nil // 不存在（或无法访问，交由后续写入报错）
+	}
+	switch policy {
+	case "", OverwriteReplace:
+		// 上次安装设为只读的文件须先去掉只读属性才能覆盖
+		_ = clearReadOnly(dest)
+		return nil
+	case OverwriteFail:
+		return fmt.Errorf("%s: %w", dest, fs.ErrExist)
+	case OverwriteBackup:
+		bak := dest + ".bak"
+		_ = removeFile(bak)
+		if err := os.Rename(dest, bak); err != nil {
+			return fmt.Errorf("backup %s: %w", dest, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown overwrite policy %q", policy)
+	}
+}
+
+// DecideInstallDir 决定并创建安装目录：forced 优先（先展开其中的环境变量，见 ExpandPath）；其次在 Windows 上按安装范围选择
+// ProgramFiles（全部用户）或 %LOCALAPPDATA%\Programs（当前用户）；最后当前目录
+func DecideInstallDir(productName, forced string, perMachine bool) (string, error) {
+	if forced != "" {
+		forced = ExpandPath(forced)
+		return forced, os.MkdirAll(forced, 0o755)
+	}
+	if runtime.GOOS == "windows" {
+		if perMachine {
+			if pf := os.Getenv("ProgramFiles"); pf != "" {
+				path := filepath.Join(pf, productName)
+				return path, os.MkdirAll(path, 0o755)
+			}
+		} else if la := os.Getenv("LOCALAPPDATA"); la != "" {
+			path := filepath.Join(la, "Programs", productName)
+			return path, os.MkdirAll(path, 0o755)
+		}
+	}
+	cwd, _ := os.Getwd()
+	path := filepath.Join(cwd, productName)
+	return path, os.MkdirAll(path, 0o755)
+}
+
+// CheckWritable 在 dir 中创建并删除一个临时文件，确认在清理或写入之前就能发现权限问题
+func CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+var envVarPattern = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ExpandPath 展开路径中的环境变量，同时支持 Windows 的 %VAR% 与 Unix 的 $VAR、${VAR}。
+// 未定义的变量保持原样（如 "%NOPE%\App" 不变），便于在后续错误信息中看出问题。
+func ExpandPath(p string) string {
+	return envVarPattern.ReplaceAllStringFunc(p, func(m string) string {
+		sub := envVarPattern.FindStringSubmatch(m)
+		name := sub[1] + sub[2] + sub[3]
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		return m
+	})
+}
+
+// ExePath 返回主程序在安装目录中的路径。跨平台安装包常用同一个 ExeName（如 app.exe），
+// 非 Windows 平台上该文件不存在而去掉 .exe 的文件存在时，使用后者。
+func (m InstallMeta) ExePath(installDir string) string {
+	p := filepath.Join(installDir, m.ExeName)
+	if runtime.GOOS == "windows" || !strings.EqualFold(filepath.Ext(p), ".exe") {
+		return p
+	}
+	if _, err := os.Stat(p); err != nil {
+		alt := p[:len(p)-len(".exe")]
+		if _, err := os.Stat(alt); err == nil {
+			return alt
+		}
+	}
+	return p
+}
+
+// DetectAnyExe 若指定 exeName 不存在，兜底寻找一个 .exe
+func DetectAnyExe(root string) string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if strings.HasSuffix(strings.ToLower(name), ".exe") {
+			return filepath.Join(root, name)
+		}
+	}
+	// 进一步递归一层（可选）
+	for _, e := range entries {
+		if e.IsDir() {
+			sub := filepath.Join(root, e.Name())
+			files, _ := os.ReadDir(sub)
+			for _, se := range files {
+				if !se.IsDir() && strings.HasSuffix(strings.ToLower(se.Name()), ".exe") {
+					return filepath.Join(sub, se.Name())
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ========== 目录清理（安全） ==========
+
+// CountFiles 递归统计 dir 下 CleanInstallDir 将删除的文件数（不含目录）：preserve 中相对路径下的文件
+// 与正在运行的程序不计入；dir 不存在时返回 0
+func CountFiles(dir string, preserve ...string) (int, error) {
+	preserve = cleanKeep(dir, preserve)
+	n := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if rel, _ := filepath.Rel(dir, p); p != dir && underAny(filepath.ToSlash(rel), preserve) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// CleanInstallDir 清空安装目录内容（保留目录本身），带有防误删保护（见 cleanguard.go，
+// 取 meta 中的 ProductName 与 AllowAnyInstallDir）。preserve 中的相对路径（文件或目录，如 PreservedPaths 的结果）不删除，位于目录内的当前进程程序
+// （如在安装目录中运行的安装器或卸载程序）也始终保留。
+func CleanInstallDir(dir string, meta InstallMeta, preserve ...string) error {
+	// 若不存在则直接创建由调用者继续
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("目标路径存在但不是目录: %s", dir)
+	}
+	// 安全保护：禁止删除卷根、系统目录及其上级等敏感位置（见 cleanguard.go）
+	if err := checkCleanTarget(dir, meta.ProductName, meta.AllowAnyInstallDir); err != nil {
+		return err
+	}
+	keep := cleanKeep(dir, preserve)
+	errs := removeTree(dir, "", keep, func(rel string) bool { return underAny(rel, keep) })
+	if len(errs) > 0 {
+		return fmt.Errorf("%d entries could not be removed, first: %w", len(errs), errs[0])
+	}
+	return nil
+}
+
+// cleanKeep 规范化 preserve（\ 与 / 均视为分隔符，同 LocalPath），并加入位于 dir 内的当前进程程序
+// （正在运行的安装程序 / 卸载程序，正常情况下不在此目录）
+func cleanKeep(dir string, preserve []string) []string {
+	keep := make([]string, 0, len(preserve)+1)
+	for _, p := range preserve {
+		keep = append(keep, path.Clean(strings.ReplaceAll(p, `\`, "/")))
+	}
+	if exe, err := executable(); err == nil {
+		if abs, err := filepath.Abs(dir); err == nil && isWithin(exe, abs) {
+			rel, _ := filepath.Rel(abs, exe)
+			keep = append(keep, filepath.ToSlash(rel))
+		}
+	}
+	return keep
+}
+
+// PreservedPaths 覆盖安装清理目录时应保留的路径：新版本与已安装版本（安装清单）的 PreserveDirs
+func PreservedPaths(installDir string, meta InstallMeta) []string {
+	preserve := append([]string(nil), meta.PreserveDirs...)
+	if old, err := ReadManifest(installDir); err == nil {
+		preserve = append(preserve, old.PreserveDirs...)
+	}
+	return preserve
+}
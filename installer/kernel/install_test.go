@@ -0,0 +1,279 @@
+package kernel
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("DEMO_BASE", "/opt/base")
+	t.Setenv("ProgramFiles(x86)", `C:\Program Files (x86)`)
+
+	tests := []struct {
+		in, want string
+	}{
+		{`%DEMO_BASE%\MyApp`, `/opt/base\MyApp`},
+		{"$DEMO_BASE/MyApp", "/opt/base/MyApp"},
+		{"${DEMO_BASE}/MyApp", "/opt/base/MyApp"},
+		{"${DEMO_BASE}MyApp", "/opt/baseMyApp"},
+		{`%ProgramFiles(x86)%\MyApp`, `C:\Program Files (x86)\MyApp`},
+		{`%DEMO_UNDEFINED%\MyApp`, `%DEMO_UNDEFINED%\MyApp`},
+		{"$DEMO_UNDEFINED/MyApp", "$DEMO_UNDEFINED/MyApp"},
+		{"${DEMO_UNDEFINED}/MyApp", "${DEMO_UNDEFINED}/MyApp"},
+		{"100%/MyApp", "100%/MyApp"},
+		{`C:\MyApp`, `C:\MyApp`},
+	}
+	for _, tt := range tests {
+		if got := ExpandPath(tt.in); got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInstallFromArchiveRejectsExeNameEscape(t *testing.T) {
+	archive, err := BuildTarGz(map[string][]byte{"app.exe": []byte("exe")}, gzip.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	dir := filepath.Join(root, "Demo")
+	for _, name := range []string{`..\..\system32\evil.exe`, "../evil.exe", "/tmp/evil.exe", `C:\evil.exe`} {
+		err := InstallFromArchive(archive, dir, InstallMeta{ProductName: "Demo", ExeName: name})
+		if err == nil {
+			t.Errorf("InstallFromArchive() with ExeName %q should fail", name)
+		}
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("InstallFromArchive() wrote %d entries despite an invalid ExeName", len(entries))
+	}
+}
+
+func TestWriteFilesOverwritePolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr error
+		want    map[string]string // 写入后的目录内容，nil 表示不检查
+	}{
+		{"", nil, map[string]string{"app.exe": "new", "new.txt": "new"}},
+		{OverwriteReplace, nil, map[string]string{"app.exe": "new", "new.txt": "new"}},
+		{OverwriteBackup, nil, map[string]string{"app.exe": "new", "app.exe.bak": "old", "new.txt": "new"}},
+		// fail 先检查全部目标，冲突时一个文件都不写
+		{OverwriteFail, fs.ErrExist, map[string]string{"app.exe": "old"}},
+		{"bogus", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, map[string]string{"app.exe": "old"})
+			files := []*InMemoryFile{{Name: "app.exe", Data: []byte("new")}, {Name: "new.txt", Data: []byte("new")}}
+			err := WriteFiles(files, dir, WriteOptions{Overwrite: tt.policy, Workers: 1})
+			if tt.want == nil {
+				if err == nil {
+					t.Fatal("WriteFiles() with an unknown policy should fail")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteFiles() error = %v, want %v", err, tt.wantErr)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.want) {
+				t.Errorf("WriteFiles() left %d files, want %d", len(entries), len(tt.want))
+			}
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil || string(got) != want {
+					t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPreserveTimestamps(t *testing.T) {
+	mtime := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	archive, err := BuildTarGzWithTimes(
+		map[string][]byte{"app.exe": []byte("exe"), "doc/readme.txt": []byte("doc")},
+		map[string]time.Time{"app.exe": mtime, "doc/readme.txt": mtime},
+		gzip.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := map[string]func(dir string, opts WriteOptions) error{
+		"WriteFiles": func(dir string, opts WriteOptions) error {
+			files, err := UntarGzToMemory(archive, DefaultArchiveLimits)
+			if err != nil {
+				return err
+			}
+			return WriteFiles(files, dir, opts)
+		},
+		"StreamToDir": func(dir string, opts WriteOptions) error {
+			_, err := StreamToDir(archive, dir, DefaultArchiveLimits, opts)
+			return err
+		},
+	}
+	for name, fn := range write {
+		for _, preserve := range []bool{true, false} {
+			dir := t.TempDir()
+			if err := fn(dir, WriteOptions{PreserveTimestamps: preserve}); err != nil {
+				t.Fatalf("%s() error = %v", name, err)
+			}
+			for _, f := range []string{"app.exe", "doc/readme.txt"} {
+				st, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := st.ModTime().Equal(mtime); got != preserve {
+					t.Errorf("%s(PreserveTimestamps=%v): %s mtime = %v, archive has %v", name, preserve, f, st.ModTime(), mtime)
+				}
+			}
+		}
+	}
+}
+
+// deepTree 生成 depth 层、每层 width 个文件的目录树条目。文件排在其目录条目之前，
+// 检验 WriteFiles 不依赖条目顺序创建上级目录
+func deepTree(depth, width int) []*InMemoryFile {
+	var files, dirs []*InMemoryFile
+	dir := ""
+	for d := range depth {
+		dir += fmt.Sprintf("level%d/", d)
+		dirs = append(dirs, &InMemoryFile{Name: dir})
+		for w := range width {
+			name := fmt.Sprintf("%sfile%d.bin", dir, w)
+			files = append(files, &InMemoryFile{Name: name, Data: []byte(name)})
+		}
+	}
+	return append(files, dirs...)
+}
+
+func TestWriteFilesWorkers(t *testing.T) {
+	files := deepTree(12, 8)
+	var total int64
+	for _, f := range files {
+		total += int64(len(f.Data))
+	}
+	for _, workers := range []int{0, 1, 4, 32} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			dir := t.TempDir()
+			progress := NewProgress()
+			events := 0
+			progress.Subscribe(func(ProgressEvent) { events++ }) // 回调串行执行，不加锁也不会竞争
+			if err := WriteFiles(files, dir, WriteOptions{Progress: progress, Workers: workers}); err != nil {
+				t.Fatalf("WriteFiles() error = %v", err)
+			}
+			for _, f := range files {
+				if f.Data == nil {
+					continue
+				}
+				got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Name)))
+				if err != nil || string(got) != string(f.Data) {
+					t.Errorf("%s = %q, %v; want %q", f.Name, got, err, f.Data)
+				}
+			}
+			ev := progress.Snapshot()
+			if ev.Done != len(files) || ev.Current != total {
+				t.Errorf("progress = %d items / %d bytes, want %d / %d", ev.Done, ev.Current, len(files), total)
+			}
+			if events != len(files)+1 {
+				t.Errorf("got %d progress events, want %d", events, len(files)+1)
+			}
+		})
+	}
+}
+
+// 用 go test -race -bench WriteFiles 检查并发写入
+func BenchmarkWriteFiles(b *testing.B) {
+	files := deepTree(20, 16)
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for range b.N {
+				if err := WriteFiles(files, b.TempDir(), WriteOptions{Workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanInstallDir(t *testing.T) {
+	tree := map[string]string{
+		"Demo.exe": "exe", "bin/lib.dll": "dll", "bin/uninstall.exe": "running",
+		"data/save.dat": "save", "data/cache/tmp": "tmp", "settings.ini": "user",
+	}
+	tests := []struct {
+		name        string
+		preserve    []string
+		running     string // 当前进程程序相对安装目录的路径，空表示不在目录内
+		wantCount   int
+		wantPresent []string
+	}{
+		{
+			name:      "removes everything",
+			wantCount: 6,
+		},
+		{
+			name:        "preserved dir and file",
+			preserve:    []string{"data", "settings.ini"},
+			wantCount:   3,
+			wantPresent: []string{"data/save.dat", "data/cache/tmp", "settings.ini"},
+		},
+		{
+			name:        "preserved nested dir",
+			preserve:    []string{`data\cache\`},
+			wantCount:   5,
+			wantPresent: []string{"data/cache/tmp"},
+		},
+		{
+			name:        "running uninstaller",
+			running:     "bin/uninstall.exe",
+			wantCount:   5,
+			wantPresent: []string{"bin/uninstall.exe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(installRoot(t), "Demo")
+			writeTree(t, dir, tree)
+			if tt.running != "" {
+				exe := filepath.Join(dir, filepath.FromSlash(tt.running))
+				executable = func() (string, error) { return exe, nil }
+				t.Cleanup(func() { executable = os.Executable })
+			}
+
+			n, err := CountFiles(dir, tt.preserve...)
+			if err != nil || n != tt.wantCount {
+				t.Errorf("CountFiles() = %d, %v; want %d", n, err, tt.wantCount)
+			}
+			if err := CleanInstallDir(dir, InstallMeta{ProductName: "Demo"}, tt.preserve...); err != nil {
+				t.Fatalf("CleanInstallDir() error = %v", err)
+			}
+			var left []string
+			_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(dir, p)
+					left = append(left, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if len(left) != len(tt.wantPresent) {
+				t.Errorf("CleanInstallDir() left %v, want %v", left, tt.wantPresent)
+			}
+			for _, p := range tt.wantPresent {
+				if !exists(filepath.Join(dir, filepath.FromSlash(p))) {
+					t.Errorf("%s should have been kept", p)
+				}
+			}
+		})
+	}
+
+	if err := CleanInstallDir(filepath.Join(t.TempDir(), "Other"), InstallMeta{ProductName: "Demo"}); err != nil {
+		t.Errorf("CleanInstallDir() of a missing dir error = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package kernel
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// 完整性校验：尾部的校验和为安装器从文件开头到尾部之前（stub + 归档 + 签名块）的 SHA-256，
+// 用于发现传输中损坏的安装器。Authenticode 签名会改写 PE 头中的 CheckSum 与证书表目录项，
+// 并把证书追加在尾部之后，因此计算时这两个字段按 0 处理，尾部之后的内容不参与计算，
+// 签名前后哈希保持一致。
+
+// ErrCorrupted 安装器完整性校验失败
+var ErrCorrupted = errors.New("installer file is corrupted")
+
+// IntegrityHash 计算 r 中 [0, n) 的完整性哈希（PE 头中签名会改写的字段按 0 处理）
+func IntegrityHash(r io.ReaderAt, n int64) ([32]byte, error) {
+	var sum [32]byte
+	h := sha256.New()
+	if err := writeIntegrityStream(h, r, n, peSigningFields(r, n)); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// integrityHashCapture 同 IntegrityHash，并在同一遍读取中把 [start, start+len(capture)) 复制到 capture
+// 并计算这一段的 SHA-256。安装器据此只读一遍文件，同时完成完整性校验、读取归档与计算归档哈希。
+// 该段与按 0 处理的 PE 字段重叠时（只会出现在构造出的文件中）不复制，captured 为 false，由调用方另行读取。
+func integrityHashCapture(r io.ReaderAt, n, start int64, capture []byte) (sum, captureSum [32]byte, captured bool, err error) {
+	fields := peSigningFields(r, n)
+	for _, rg := range fields {
+		if rg[0] < start+int64(len(capture)) && start < rg[1] {
+			capture = nil
+		}
+	}
+	h := sha256.New()
+	c := &rangeCapture{start: start, buf: capture, h: sha256.New()}
+	if err = writeIntegrityStream(io.MultiWriter(h, c), r, n, fields); err != nil {
+		return sum, captureSum, false, err
+	}
+	copy(sum[:], h.Sum(nil))
+	copy(captureSum[:], c.h.Sum(nil))
+	return sum, captureSum, capture != nil, nil
+}
+
+// writeIntegrityStream 将 r 中 [0, n) 顺序写入 w，fields 中的字段（peSigningFields 的结果）写为 0
+func writeIntegrityStream(w io.Writer, r io.ReaderAt, n int64, fields [][2]int64) error {
+	var pos int64
+	for _, rg := range fields {
+		if _, err := io.Copy(w, io.NewSectionReader(r, pos, rg[0]-pos)); err != nil {
+			return err
+		}
+		if _, err := w.Write(make([]byte, rg[1]-rg[0])); err != nil {
+			return err
+		}
+		pos = rg[1]
+	}
+	_, err := io.Copy(w, io.NewSectionReader(r, pos, n-pos))
+	return err
+}
+
+// rangeCapture 记录写入流中 [start, start+len(buf)) 的内容及其哈希
+type rangeCapture struct {
+	pos, start int64
+	buf        []byte
+	h          hash.Hash
+}
+
+func (c *rangeCapture) Write(p []byte) (int, error) {
+	lo := max(c.start, c.pos)
+	hi := min(c.start+int64(len(c.buf)), c.pos+int64(len(p)))
+	if lo < hi {
+		seg := p[lo-c.pos : hi-c.pos]
+		copy(c.buf[lo-c.start:], seg)
+		c.h.Write(seg)
+	}
+	c.pos += int64(len(p))
+	return len(p), nil
+}
+
+// peSigningFields 返回 PE 可选头中 CheckSum 与证书表目录项的 [起, 止) 偏移（按偏移升序）；
+// 不是 PE 文件（如其他平台的 stub）时返回 nil
+func peSigningFields(r io.ReaderAt, n int64) [][2]int64 {
+	var dos [64]byte
+	if n < int64(len(dos)) {
+		return nil
+	}
+	if _, err := r.ReadAt(dos[:], 0); err != nil || dos[0] != 'M' || dos[1] != 'Z' {
+		return nil
+	}
+	pe := int64(binary.LittleEndian.Uint32(dos[0x3c:]))
+	// PE 签名 4 + COFF 头 20 + 可选头 Magic 2
+	var hdr [26]byte
+	if pe+int64(len(hdr)) > n {
+		return nil
+	}
+	if _, err := r.ReadAt(hdr[:], pe); err != nil || string(hdr[:4]) != "PE\x00\x00" {
+		return nil
+	}
+	opt := pe + 24
+	var dirs int64
+	switch binary.LittleEndian.Uint16(hdr[24:]) {
+	case 0x10b: // PE32
+		dirs = opt + 96
+	case 0x20b: // PE32+
+		dirs = opt + 112
+	default:
+		return nil
+	}
+	checksum := opt + 64
+	cert := dirs + 4*8 // IMAGE_DIRECTORY_ENTRY_SECURITY
+	if cert+8 > n {
+		return nil
+	}
+	return [][2]int64{{checksum, checksum + 4}, {cert, cert + 8}}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package kernel
+
+// FileInUse 非 Windows 平台覆盖正在运行的文件不会失败，恒为 false
+func FileInUse(path string) bool { return false }
+
+// IsFileInUseError 非 Windows 平台恒为 false
+func IsFileInUseError(err error) bool { return false }
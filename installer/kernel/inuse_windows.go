@@ -0,0 +1,29 @@
+//go:build windows
+
+package kernel
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// FileInUse 报告 path 是否正被其他进程占用（如正在运行的 exe）。
+// 以独占写方式尝试打开：文件不存在或可独占打开时返回 false。
+func FileInUse(path string) bool {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+	h, err := windows.CreateFile(p, windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return IsFileInUseError(err)
+	}
+	windows.CloseHandle(h)
+	return false
+}
+
+// IsFileInUseError 判断 err 是否为共享/锁定冲突
+func IsFileInUseError(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
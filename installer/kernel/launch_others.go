@@ -0,0 +1,20 @@
+//go:build !windows
+
+package kernel
+
+import (
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// LaunchDetached 以新会话启动 exePath（工作目录为其所在目录），不等待其结束
+func LaunchDetached(exePath string, args ...string) error {
+	cmd := exec.Command(exePath, args...)
+	cmd.Dir = filepath.Dir(exePath)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
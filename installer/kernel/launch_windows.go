@@ -0,0 +1,25 @@
+//go:build windows
+
+package kernel
+
+import (
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// LaunchDetached 以独立进程启动 exePath（工作目录为其所在目录），不等待其结束，
+// 也不继承控制台，安装器可以立即退出。
+func LaunchDetached(exePath string, args ...string) error {
+	cmd := exec.Command(exePath, args...)
+	cmd.Dir = filepath.Dir(exePath)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.DETACHED_PROCESS | windows.CREATE_NEW_PROCESS_GROUP,
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package kernel
+
+import "os"
+
+// DetectLanguage 按 POSIX 约定依次读取 LC_ALL、LC_MESSAGES、LANG
+func DetectLanguage() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package kernel
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetUserDefaultLocaleName = syscall.NewLazyDLL("kernel32.dll").NewProc("GetUserDefaultLocaleName")
+
+// DetectLanguage 读取当前用户的区域设置名称（如 "zh-CN"）
+func DetectLanguage() string {
+	const localeNameMaxLength = 85
+	buf := make([]uint16, localeNameMaxLength)
+	n, _, _ := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
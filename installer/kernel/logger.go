@@ -0,0 +1,91 @@
+package kernel
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel 日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l LogLevel) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLogLevel 解析 "debug"、"info"、"warn"、"error"（不区分大小写），空串为 LevelInfo
+func ParseLogLevel(s string) (LogLevel, error) {
+	if s == "" {
+		return LevelInfo, nil
+	}
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q", s)
+}
+
+// Logger 分级日志。控制台只输出不低于当前级别的消息，且不带前缀（与交互提示保持一致）；
+// 日志文件记录全部级别，并附带时间与级别，便于事后排查。
+type Logger struct {
+	mu      sync.Mutex
+	level   LogLevel
+	console io.Writer
+	file    io.Writer
+}
+
+// Log 安装器与 kernel 共用的日志
+var Log = &Logger{level: LevelInfo, console: os.Stdout}
+
+// SetLevel 设置控制台输出的最低级别
+func (l *Logger) SetLevel(level LogLevel) {
+	l.mu.Lock()
+	l.level = level
+	l.mu.Unlock()
+}
+
+// SetOutput 设置控制台输出，nil 表示不输出
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	l.console = w
+	l.mu.Unlock()
+}
+
+// SetFile 设置日志文件，nil 表示不写文件
+func (l *Logger) SetFile(w io.Writer) {
+	l.mu.Lock()
+	l.file = w
+	l.mu.Unlock()
+}
+
+func (l *Logger) Debug(msg string) { l.log(LevelDebug, msg) }
+func (l *Logger) Info(msg string)  { l.log(LevelInfo, msg) }
+func (l *Logger) Warn(msg string)  { l.log(LevelWarn, msg) }
+func (l *Logger) Error(msg string) { l.log(LevelError, msg) }
+
+func (l *Logger) log(level LogLevel, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.console != nil && level >= l.level {
+		fmt.Fprintln(l.console, msg)
+	}
+	if l.file != nil {
+		fmt.Fprintf(l.file, "%s [%-5s] %s\n", time.Now().Format("2006-01-02 15:04:05.000"), level, msg)
+	}
+}
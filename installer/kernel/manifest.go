@@ -0,0 +1,347 @@
+package kernel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestName 安装清单文件名，位于安装目录根部
+const ManifestName = "install-manifest.json"
+
+// ManifestEntry 清单中的一个文件
+type ManifestEntry struct {
+	Path   string `json:"path"` // 相对安装目录，以 / 分隔
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest 安装清单：记录安装时写入的每个文件及其校验和，用于修复
+type Manifest struct {
+	ProductName string          `json:"productName"`
+	Version     string          `json:"version"`
+	Files       []ManifestEntry `json:"files"`
+	// Shortcuts 安装时创建的快捷方式文件（绝对路径），卸载时删除
+	Shortcuts []string `json:"shortcuts,omitempty"`
+	// StartMenuFolder 安装时创建的开始菜单程序文件夹（绝对路径），卸载时整个删除
+	StartMenuFolder string `json:"startMenuFolder,omitempty"`
+	// PreserveDirs 卸载时始终保留的目录，来自 InstallMeta.PreserveDirs
+	PreserveDirs []string `json:"preserveDirs,omitempty"`
+	// Generated 安装时由模板生成的文件（见 RenderTemplates），不在归档中，修复时跳过，卸载时与安装的文件一并删除
+	Generated []string `json:"generated,omitempty"`
+	// KeepInstallDir 卸载时保留安装目录本身及未记录的文件，来自 InstallMeta.KeepInstallDir
+	KeepInstallDir bool `json:"keepInstallDir,omitempty"`
+	// AllowAnyInstallDir 卸载时不要求安装目录位于常规安装位置，来自 InstallMeta.AllowAnyInstallDir
+	AllowAnyInstallDir bool `json:"allowAnyInstallDir,omitempty"`
+}
+
+// BuildManifest 根据归档条目生成清单（目录条目不记录）
+func BuildManifest(meta InstallMeta, files []*InMemoryFile) Manifest {
+	var entries []ManifestEntry
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		sum := sha256.Sum256(f.Data)
+		entries = append(entries, ManifestEntry{
+			Path:   f.Name,
+			Size:   int64(len(f.Data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	return ManifestFor(meta, entries)
+}
+
+// ManifestFor 用已计算好的文件条目（如 StreamToDir 的返回值）生成清单
+func ManifestFor(meta InstallMeta, entries []ManifestEntry) Manifest {
+	m := Manifest{
+		ProductName:        meta.ProductName,
+		Version:            meta.Version,
+		Files:              entries,
+		KeepInstallDir:     meta.KeepInstallDir,
+		AllowAnyInstallDir: meta.AllowAnyInstallDir,
+	}
+	for _, d := range meta.PreserveDirs {
+		if d = strings.Trim(filepath.ToSlash(d), "/"); d != "" {
+			m.PreserveDirs = append(m.PreserveDirs, d)
+		}
+	}
+	return m
+}
+
+// ManifestDiff 新版本文件与已安装清单的差异（归档内路径）
+type ManifestDiff struct {
+	Added     []string // 新版本新增的文件
+	Replaced  []string // 两边都有但内容不同的文件
+	Removed   []string // 已安装但新版本中没有的文件
+	Unchanged int      // 内容相同的文件数
+}
+
+// DiffManifest 比较已安装清单 old 与新版本的文件条目（路径不区分大小写，与 Windows 一致）
+func DiffManifest(old Manifest, files []ManifestEntry) ManifestDiff {
+	var d ManifestDiff
+	installed := make(map[string]ManifestEntry, len(old.Files))
+	for _, e := range old.Files {
+		installed[strings.ToLower(e.Path)] = e
+	}
+	for _, e := range files {
+		key := strings.ToLower(e.Path)
+		prev, ok := installed[key]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e.Path)
+		case prev.SHA256 != e.SHA256 || prev.Size != e.Size:
+			d.Replaced = append(d.Replaced, e.Path)
+		default:
+			d.Unchanged++
+		}
+		delete(installed, key)
+	}
+	for _, e := range old.Files {
+		if _, ok := installed[strings.ToLower(e.Path)]; ok {
+			d.Removed = append(d.Removed, e.Path)
+		}
+	}
+	return d
+}
+
+// WriteManifest 将清单写入 dir/ManifestName
+func WriteManifest(dir string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestName), data, 0o644)
+}
+
+// ReadManifest 读取 dir 下的安装清单
+func ReadManifest(dir string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parse %s: %w", ManifestName, err)
+	}
+	return m, nil
+}
+
+// VerifyFiles 返回 dir 中缺失、大小不符或校验和不符的清单条目
+func VerifyFiles(dir string, m Manifest) []ManifestEntry {
+	var bad []ManifestEntry
+	for _, e := range m.Files {
+		p, ok := entryPath(dir, e.Path)
+		if !ok {
+			continue
+		}
+		if ok, _ := fileMatches(p, e); !ok {
+			bad = append(bad, e)
+		}
+	}
+	return bad
+}
+
+// entryPath 返回清单条目在 dir 中的路径。清单位于安装目录中、可能被改写，
+// 跳出 dir 的条目（绝对路径、盘符、..）一律不处理，ok 为 false
+func entryPath(dir, rel string) (p string, ok bool) {
+	n, err := LocalPath(rel)
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(n)), true
+}
+
+// InstalledSize 返回清单记录的文件与生成文件的总字节数，用作注册表中的 EstimatedSize。
+// 只统计本次安装写入的内容，保留的用户数据与安装目录中原有的其他文件不计入；
+// 生成文件的大小不在清单中，按 dir 中的实际文件统计，不存在的跳过
+func (m Manifest) InstalledSize(dir string) int64 {
+	var total int64
+	for _, e := range m.Files {
+		total += e.Size
+	}
+	for _, g := range m.Generated {
+		p, ok := entryPath(dir, g)
+		if !ok {
+			continue
+		}
+		if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() {
+			total += st.Size()
+		}
+	}
+	return total
+}
+
+// RepairFiles 只重写 dir 中缺失或损坏的文件，其余文件（含用户数据）保持不动，返回修复的文件数。
+// 归档中对应文件的校验和必须与清单一致，否则说明安装器与已安装版本不同，拒绝修复。
+func RepairFiles(files []*InMemoryFile, dir string, m Manifest, progress *Progress) (int, error) {
+	var repair []*InMemoryFile
+	for _, e := range VerifyFiles(dir, m) {
+		f := FindFile(files, e.Path)
+		if f == nil {
+			return 0, fmt.Errorf("%s not found in archive", e.Path)
+		}
+		sum := sha256.Sum256(f.Data)
+		if hex.EncodeToString(sum[:]) != e.SHA256 {
+			return 0, fmt.Errorf("%s in archive does not match installed version %s", e.Path, m.Version)
+		}
+		repair = append(repair, f)
+	}
+	if err := WriteFiles(repair, dir, WriteOptions{Progress: progress}); err != nil {
+		return 0, err
+	}
+	return len(repair), nil
+}
+
+// RemoveInstalledFiles 删除安装目录中的内容。keepUserData 为 true 时只删除清单记录的文件（及清单本身），
+// 安装后新建的文件视为用户数据保留；否则删除全部内容。两种方式都保留 m.PreserveDirs 中的目录
+// 与 skip 中的路径（如正在运行的卸载程序），并删除因此变空的子目录。
+func RemoveInstalledFiles(dir string, m Manifest, keepUserData bool, skip ...string) error {
+	return removeInstalledFiles(dir, m, keepUserData, nil, nil, skip)
+}
+
+// removeInstalledFiles 即 RemoveInstalledFiles，逐个删除清单记录的文件并上报进度（PhaseRemove），
+// 每个文件删除前检查 cancel，已取消时返回 ErrCancelled。安装清单本身最后删除，中途取消后仍可再次卸载。
+// 删除全部内容时，清单之外的剩余内容作为最后一个条目（安装目录本身）上报。
+func removeInstalledFiles(dir string, m Manifest, keepUserData bool, progress *Progress, cancel <-chan struct{}, skip []string) error {
+	keep := func(rel string) bool {
+		for _, s := range skip {
+			if samePath(filepath.Join(dir, filepath.FromSlash(rel)), s) {
+				return true
+			}
+		}
+		return underAny(rel, m.PreserveDirs)
+	}
+
+	var errs []error
+	var tracked []ManifestEntry
+	tracked = append(tracked, m.Files...)
+	for _, g := range m.Generated {
+		tracked = append(tracked, ManifestEntry{Path: g})
+	}
+	tracked = append(tracked, ManifestEntry{Path: ManifestName})
+	count := len(tracked)
+	if !keepUserData {
+		count++
+	}
+	progress.StartPhase(PhaseRemove, 0, count)
+	for _, e := range tracked {
+		select {
+		case <-cancel:
+			pruneEmptyDirs(dir, m.PreserveDirs)
+			return ErrCancelled
+		default:
+		}
+		p, ok := entryPath(dir, e.Path)
+		if !ok {
+			Log.Warn(T("manifestEntrySkipped", e.Path))
+			progress.AddItem(e.Path, e.Size, false)
+			continue
+		}
+		if !keep(e.Path) {
+			if err := removeFile(p); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+		progress.AddItem(p, e.Size, false)
+	}
+	if !keepUserData {
+		// 子目录中有跳过的文件时同保留目录一样逐层处理，而不是整个删除
+		held := m.PreserveDirs
+		for _, s := range skip {
+			if rel, err := filepath.Rel(dir, s); err == nil && filepath.IsLocal(rel) {
+				held = append(held[:len(held):len(held)], filepath.ToSlash(rel))
+			}
+		}
+		errs = append(errs, removeTree(dir, "", held, keep)...)
+		progress.AddItem(dir, 0, true)
+	}
+	pruneEmptyDirs(dir, m.PreserveDirs)
+	if len(errs) > 0 {
+		return fmt.Errorf("%d entries could not be removed, first: %w", len(errs), errs[0])
+	}
+	return nil
+}
+
+// removeTree 删除 dir/rel 下除 keep 外的所有条目；包含 held 中路径的子目录递归处理
+func removeTree(dir, rel string, held []string, keep func(string) bool) []error {
+	entries, err := os.ReadDir(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range entries {
+		child := path.Join(rel, e.Name())
+		switch {
+		case keep(child):
+		case e.IsDir() && containsAny(child, held):
+			errs = append(errs, removeTree(dir, child, held, keep)...)
+		default:
+			if err := removeAll(filepath.Join(dir, filepath.FromSlash(child))); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// pruneEmptyDirs 自底向上删除 dir 下的空子目录（保留目录及 dir 本身除外）
+func pruneEmptyDirs(dir string, preserve []string) {
+	var dirs []string
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && p != dir {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, _ := filepath.Rel(dir, dirs[i])
+		if !underAny(filepath.ToSlash(rel), preserve) {
+			_ = os.Remove(dirs[i]) // 非空目录删除失败，忽略
+		}
+	}
+}
+
+// underAny 报告 rel 是否等于或位于 dirs 中某个目录之下
+func underAny(rel string, dirs []string) bool {
+	for _, d := range dirs {
+		if strings.EqualFold(rel, d) || strings.HasPrefix(strings.ToLower(rel), strings.ToLower(d)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny 报告 dirs 中是否有目录位于 rel 之下
+func containsAny(rel string, dirs []string) bool {
+	for _, d := range dirs {
+		if strings.HasPrefix(strings.ToLower(d), strings.ToLower(rel)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func fileMatches(path string, e ManifestEntry) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil || !st.Mode().IsRegular() || st.Size() != e.Size {
+		return false, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == e.SHA256, nil
+}
@@ -0,0 +1,204 @@
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepairFiles(t *testing.T) {
+	installed := map[string]string{"Demo.exe": "exe", "bin/lib.dll": "dll"}
+
+	tests := []struct {
+		name    string
+		damage  func(dir string) // 安装后对目录的改动
+		archive map[string]string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "nothing to repair",
+			damage:  func(string) {},
+			archive: installed,
+			want:    0,
+		},
+		{
+			name:    "missing file",
+			damage:  func(dir string) { os.Remove(filepath.Join(dir, "bin", "lib.dll")) },
+			archive: installed,
+			want:    1,
+		},
+		{
+			name: "corrupted files",
+			damage: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "Demo.exe"), []byte("bad"), 0o644)
+				os.WriteFile(filepath.Join(dir, "bin", "lib.dll"), []byte("longer than before"), 0o644)
+			},
+			archive: installed,
+			want:    2,
+		},
+		{
+			name:    "user data is ignored",
+			damage:  func(dir string) { os.WriteFile(filepath.Join(dir, "settings.ini"), []byte("user"), 0o644) },
+			archive: installed,
+			want:    0,
+		},
+		{
+			name:    "archive from another version",
+			damage:  func(dir string) { os.Remove(filepath.Join(dir, "Demo.exe")) },
+			archive: map[string]string{"Demo.exe": "exe v2", "bin/lib.dll": "dll"},
+			wantErr: true,
+		},
+		{
+			name:    "file missing from archive",
+			damage:  func(dir string) { os.Remove(filepath.Join(dir, "Demo.exe")) },
+			archive: map[string]string{"bin/lib.dll": "dll"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "Demo")
+			installFixture(t, dir, installed, nil, nil)
+			m, err := ReadManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(dir)
+
+			var files []*InMemoryFile
+			for name, data := range tt.archive {
+				files = append(files, &InMemoryFile{Name: name, Data: []byte(data)})
+			}
+			n, err := RepairFiles(files, dir, m, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RepairFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if n != tt.want {
+				t.Errorf("RepairFiles() = %d, want %d", n, tt.want)
+			}
+			if bad := VerifyFiles(dir, m); len(bad) != 0 {
+				t.Errorf("VerifyFiles() after repair = %v, want none", bad)
+			}
+		})
+	}
+}
+
+func TestRemoveInstalledFiles(t *testing.T) {
+	installed := map[string]string{"Demo.exe": "exe", "bin/lib.dll": "dll", "data/default.cfg": "cfg"}
+	user := map[string]string{"settings.ini": "user", "data/save.dat": "save"}
+
+	tests := []struct {
+		name         string
+		keepUserData bool
+		preserve     []string
+		skip         []string // 相对安装目录
+		generated    []string
+		extra        []ManifestEntry // 追加到清单的条目（如被篡改的路径）
+		wantGone     []string
+		wantPresent  []string
+	}{
+		{
+			name:        "remove everything",
+			wantGone:    []string{"Demo.exe", "bin", "data", "settings.ini", ManifestName},
+			wantPresent: []string{"."},
+		},
+		{
+			name:         "keep user data",
+			keepUserData: true,
+			wantGone:     []string{"Demo.exe", "bin", "data/default.cfg", ManifestName},
+			wantPresent:  []string{"settings.ini", "data/save.dat"},
+		},
+		{
+			name:        "preserve dirs",
+			preserve:    []string{"data"},
+			wantGone:    []string{"Demo.exe", "bin", "settings.ini"},
+			wantPresent: []string{"data/default.cfg", "data/save.dat"},
+		},
+		{
+			name:        "skip",
+			skip:        []string{"bin/lib.dll"},
+			wantGone:    []string{"Demo.exe", "settings.ini"},
+			wantPresent: []string{"bin/lib.dll"},
+		},
+		{
+			name:         "generated files",
+			keepUserData: true,
+			generated:    []string{"settings.ini"},
+			wantGone:     []string{"settings.ini"},
+			wantPresent:  []string{"data/save.dat"},
+		},
+		{
+			name:         "traversal entries are skipped",
+			keepUserData: true,
+			generated:    []string{"../victim.txt"},
+			extra: []ManifestEntry{
+				{Path: "../victim.txt"},
+				{Path: `..\victim.txt`},
+				{Path: "bin/../../victim.txt"},
+				{Path: "C:/victim.txt"},
+			},
+			wantGone:    []string{"Demo.exe"},
+			wantPresent: []string{"../victim.txt", "settings.ini"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "Demo")
+			installFixture(t, dir, installed, user, tt.preserve)
+			writeTree(t, root, map[string]string{"victim.txt": "keep"})
+			m, err := ReadManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Generated = tt.generated
+			m.Files = append(m.Files, tt.extra...)
+			if len(tt.extra) > 0 {
+				// 绝对路径同样不得处理
+				m.Files = append(m.Files, ManifestEntry{Path: filepath.Join(root, "victim.txt")})
+			}
+			var skip []string
+			for _, s := range tt.skip {
+				skip = append(skip, filepath.Join(dir, filepath.FromSlash(s)))
+			}
+
+			if err := RemoveInstalledFiles(dir, m, tt.keepUserData, skip...); err != nil {
+				t.Fatalf("RemoveInstalledFiles() error = %v", err)
+			}
+			for _, p := range tt.wantGone {
+				if exists(filepath.Join(dir, filepath.FromSlash(p))) {
+					t.Errorf("%s should have been removed", p)
+				}
+			}
+			for _, p := range tt.wantPresent {
+				if !exists(filepath.Join(dir, filepath.FromSlash(p))) {
+					t.Errorf("%s should have been kept", p)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestInstalledSize(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Demo")
+	installFixture(t, dir, map[string]string{"Demo.exe": "exe", "bin/lib.dll": "dll"}, map[string]string{
+		"data/user.db": "user data that must not be counted",
+	}, []string{"data"})
+	writeTree(t, dir, map[string]string{"config.ini": "generated", "stale.log": "left over from an older version"})
+	writeTree(t, root, map[string]string{"outside.bin": "outside"})
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Generated = []string{"config.ini", "missing.ini", "../outside.bin"}
+
+	// 只计清单文件（3+3 字节）与存在的生成文件（9 字节）
+	if got, want := m.InstalledSize(dir), int64(15); got != want {
+		t.Errorf("InstalledSize() = %d, want %d", got, want)
+	}
+}
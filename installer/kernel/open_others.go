@@ -0,0 +1,21 @@
+//go:build !windows
+
+package kernel
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// OpenWithDefault 用系统默认程序打开文件或网址（macOS 为 open，其余为 xdg-open）
+func OpenWithDefault(target string) error {
+	name := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		name = "open"
+	}
+	cmd := exec.Command(name, target)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
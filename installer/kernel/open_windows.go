@@ -0,0 +1,15 @@
+//go:build windows
+
+package kernel
+
+import "golang.org/x/sys/windows"
+
+// OpenWithDefault 用系统默认程序打开文件或网址（ShellExecute "open"）
+func OpenWithDefault(target string) error {
+	verb, _ := windows.UTF16PtrFromString("open")
+	file, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	return windows.ShellExecute(0, verb, file, nil, nil, windows.SW_SHOWNORMAL)
+}
@@ -0,0 +1,101 @@
+package kernel
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// osDisplayNames 提示信息中使用的系统名称
+var osDisplayNames = map[string]string{
+	"windows": "Windows",
+	"linux":   "Linux",
+	"darwin":  "macOS",
+}
+
+// CheckPlatform 检查当前系统与架构是否在 RequiredOS / RequiredArch 列表中（为空表示不限），
+// 不符时返回描述所需平台的字符串，如 "Windows amd64"
+func (m InstallMeta) CheckPlatform() (required string, ok bool) {
+	osOK := len(m.RequiredOS) == 0 || containsFold(m.RequiredOS, runtime.GOOS)
+	archOK := len(m.RequiredArch) == 0 || containsFold(m.RequiredArch, HostArch())
+	if osOK && archOK {
+		return "", true
+	}
+	var parts []string
+	if len(m.RequiredOS) > 0 {
+		names := make([]string, len(m.RequiredOS))
+		for i, o := range m.RequiredOS {
+			names[i] = o
+			if n, ok := osDisplayNames[strings.ToLower(o)]; ok {
+				names[i] = n
+			}
+		}
+		parts = append(parts, strings.Join(names, "/"))
+	}
+	if len(m.RequiredArch) > 0 {
+		parts = append(parts, strings.Join(m.RequiredArch, "/"))
+	}
+	return strings.Join(parts, " "), false
+}
+
+// PlatformString 当前平台的描述，如 "Windows 386"
+func PlatformString() string {
+	name := runtime.GOOS
+	if n, ok := osDisplayNames[name]; ok {
+		name = n
+	}
+	return fmt.Sprintf("%s %s", name, HostArch())
+}
+
+// PlatformSelector 按 PlatformDirs 生成归档条目筛选函数（用于 ArchiveLimits.Select）：
+// 与当前平台匹配的目录去掉前缀后安装到安装目录根部，其他平台的目录整个跳过，
+// 不在任何平台目录下的条目（如 meta.json、公共资源）照常安装。未配置 PlatformDirs 时返回 nil。
+func (m InstallMeta) PlatformSelector() func(name string) (string, bool) {
+	return m.platformSelector(runtime.GOOS, HostArch())
+}
+
+// platformSelector 即 PlatformSelector，按给定的 goos / goarch 筛选
+func (m InstallMeta) platformSelector(goos, goarch string) func(name string) (string, bool) {
+	if len(m.PlatformDirs) == 0 {
+		return nil
+	}
+	return func(name string) (string, bool) {
+		// 目录嵌套时（如 "win" 与 "win/arm64"）以最长的匹配为准
+		best, bestSpec := "", ""
+		for dir, spec := range m.PlatformDirs {
+			dir = strings.Trim(strings.ReplaceAll(dir, `\`, "/"), "/")
+			if len(dir) > len(best) && (strings.EqualFold(name, dir) || strings.HasPrefix(strings.ToLower(name), strings.ToLower(dir)+"/")) {
+				best, bestSpec = dir, spec
+			}
+		}
+		switch {
+		case best == "":
+			return name, true
+		case len(name) == len(best), !matchPlatform(bestSpec, goos, goarch):
+			return "", false // 平台目录本身，或其他平台的目录
+		}
+		return name[len(best)+1:], true
+	}
+}
+
+// MatchPlatform 报告 spec（"goos" 或 "goos/goarch"，如 "windows"、"linux/arm64"）是否与当前平台匹配
+func MatchPlatform(spec string) bool {
+	return matchPlatform(spec, runtime.GOOS, HostArch())
+}
+
+func matchPlatform(spec, goos, goarch string) bool {
+	specOS, specArch, hasArch := strings.Cut(strings.TrimSpace(spec), "/")
+	if !strings.EqualFold(specOS, goos) {
+		return false
+	}
+	return !hasArch || strings.EqualFold(specArch, goarch)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package kernel
+
+import "runtime"
+
+// HostArch 返回系统架构（GOARCH 命名）；非 Windows 平台即 stub 自身的架构
+func HostArch() string { return runtime.GOARCH }
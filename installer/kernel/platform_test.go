@@ -0,0 +1,74 @@
+package kernel
+
+import "testing"
+
+func TestMatchPlatform(t *testing.T) {
+	tests := []struct {
+		spec, goos, goarch string
+		want               bool
+	}{
+		{"windows", "windows", "amd64", true},
+		{"windows", "windows", "386", true},
+		{"Windows", "windows", "amd64", true},
+		{" linux ", "linux", "arm64", true},
+		{"windows/amd64", "windows", "amd64", true},
+		{"windows/AMD64", "windows", "amd64", true},
+		{"windows/arm64", "windows", "amd64", false},
+		{"darwin", "linux", "amd64", false},
+		{"linux/amd64", "darwin", "amd64", false},
+		{"", "windows", "amd64", false},
+	}
+	for _, tt := range tests {
+		if got := matchPlatform(tt.spec, tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("matchPlatform(%q, %q, %q) = %v, want %v", tt.spec, tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestPlatformSelector(t *testing.T) {
+	meta := InstallMeta{PlatformDirs: map[string]string{
+		"win":       "windows",
+		"win/arm64": "windows/arm64",
+		`linux\`:    "linux",
+		"mac":       "darwin",
+	}}
+	names := []string{"meta.json", "common/readme.txt", "win", "win/app.exe", "win/arm64/app.exe", "linux/app", "mac/app", "Win/lib.dll", "winx/app.exe"}
+
+	tests := []struct {
+		goos, goarch string
+		want         map[string]string // 保留的条目 -> 安装路径，未列出的应跳过
+	}{
+		{"windows", "amd64", map[string]string{
+			"meta.json": "meta.json", "common/readme.txt": "common/readme.txt", "winx/app.exe": "winx/app.exe",
+			"win/app.exe": "app.exe", "Win/lib.dll": "lib.dll",
+		}},
+		{"windows", "arm64", map[string]string{
+			"meta.json": "meta.json", "common/readme.txt": "common/readme.txt", "winx/app.exe": "winx/app.exe",
+			"win/app.exe": "app.exe", "Win/lib.dll": "lib.dll", "win/arm64/app.exe": "app.exe",
+		}},
+		{"linux", "amd64", map[string]string{
+			"meta.json": "meta.json", "common/readme.txt": "common/readme.txt", "winx/app.exe": "winx/app.exe",
+			"linux/app": "app",
+		}},
+		{"darwin", "arm64", map[string]string{
+			"meta.json": "meta.json", "common/readme.txt": "common/readme.txt", "winx/app.exe": "winx/app.exe",
+			"mac/app": "app",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos+"/"+tt.goarch, func(t *testing.T) {
+			sel := meta.platformSelector(tt.goos, tt.goarch)
+			for _, name := range names {
+				got, keep := sel(name)
+				want, wantKeep := tt.want[name]
+				if keep != wantKeep || (keep && got != want) {
+					t.Errorf("select(%q) = %q, %v; want %q, %v", name, got, keep, want, wantKeep)
+				}
+			}
+		})
+	}
+
+	if (InstallMeta{}).PlatformSelector() != nil {
+		t.Error("PlatformSelector() without PlatformDirs should be nil")
+	}
+}
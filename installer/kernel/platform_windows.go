@@ -0,0 +1,36 @@
+//go:build windows
+
+package kernel
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// IsWow64Process2 自 Windows 10 1709 起提供，旧系统上退回 stub 自身的架构
+var procIsWow64Process2 = syscall.NewLazyDLL("kernel32.dll").NewProc("IsWow64Process2")
+
+// HostArch 返回系统的原生架构（GOARCH 命名）。32 位 stub 在 64 位 Windows 上运行时
+// runtime.GOARCH 为 386，这里返回系统实际的 amd64 / arm64。
+func HostArch() string {
+	if procIsWow64Process2.Find() != nil {
+		return runtime.GOARCH
+	}
+	var process, native uint16
+	h, _ := syscall.GetCurrentProcess()
+	if r, _, _ := procIsWow64Process2.Call(uintptr(h), uintptr(unsafe.Pointer(&process)), uintptr(unsafe.Pointer(&native))); r == 0 {
+		return runtime.GOARCH
+	}
+	switch native {
+	case 0x8664: // IMAGE_FILE_MACHINE_AMD64
+		return "amd64"
+	case 0xaa64: // IMAGE_FILE_MACHINE_ARM64
+		return "arm64"
+	case 0x14c: // IMAGE_FILE_MACHINE_I386
+		return "386"
+	case 0x1c4: // IMAGE_FILE_MACHINE_ARMNT
+		return "arm"
+	}
+	return runtime.GOARCH
+}
@@ -0,0 +1,108 @@
+package kernel
+
+import (
+	"sync"
+	"time"
+)
+
+// 进度阶段
+const (
+	PhaseWrite  = "write"  // 写入文件
+	PhaseRemove = "remove" // 卸载时删除文件
+	// PhaseIntegrate 文件写入之后的系统集成，每完成一步上报一个条目（Item 为下列 Step 常量），
+	// 让进度在较慢的 COM / 注册表操作期间继续推进，而不是停在写入完成处
+	PhaseIntegrate = "integrate"
+)
+
+// PhaseIntegrate 阶段的步骤
+const (
+	StepUninstaller      = "uninstaller"      // 生成卸载程序
+	StepShortcuts        = "shortcuts"        // 创建快捷方式
+	StepManifest         = "manifest"         // 写入安装清单
+	StepRegistry         = "registry"         // 写入注册表
+	StepFileAssociations = "fileAssociations" // 登记文件关联
+	StepService          = "service"          // 登记 Windows 服务
+)
+
+// ProgressEvent 一次进度快照
+type ProgressEvent struct {
+	Phase   string  // 当前阶段，如 PhaseWrite
+	Current int64   // 本阶段已完成字节数
+	Total   int64   // 本阶段总字节数
+	Done    int     // 本阶段已完成条目数
+	Count   int     // 本阶段总条目数
+	Speed   float64 // 本阶段平均速度（字节/秒）
+	Item    string  // 刚完成的条目（如文件路径）
+	Size    int64   // 刚完成条目的字节数
+	IsDir   bool    // 刚完成的条目是否为目录
+}
+
+// Progress 线程安全的进度聚合器：各阶段通过它上报进度，订阅者（控制台、GUI、库调用方）收到事件。
+// 回调在上报进度的 goroutine 中同步执行，不持有内部锁。nil *Progress 的所有方法均为空操作。
+type Progress struct {
+	mu      sync.Mutex
+	ev      ProgressEvent
+	started time.Time
+	subs    []func(ProgressEvent)
+}
+
+// NewProgress 创建进度聚合器
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Subscribe 注册进度回调
+func (p *Progress) Subscribe(fn func(ProgressEvent)) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.subs = append(p.subs, fn)
+	p.mu.Unlock()
+}
+
+// StartPhase 开始新阶段并重置计数
+func (p *Progress) StartPhase(phase string, total int64, count int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.ev = ProgressEvent{Phase: phase, Total: total, Count: count}
+	p.started = time.Now()
+	ev, subs := p.ev, p.subs
+	p.mu.Unlock()
+	notify(subs, ev)
+}
+
+// AddItem 上报完成一个条目
+func (p *Progress) AddItem(item string, size int64, isDir bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.ev.Current += size
+	p.ev.Done++
+	p.ev.Item, p.ev.Size, p.ev.IsDir = item, size, isDir
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		p.ev.Speed = float64(p.ev.Current) / elapsed
+	}
+	ev, subs := p.ev, p.subs
+	p.mu.Unlock()
+	notify(subs, ev)
+}
+
+// Snapshot 返回当前进度
+func (p *Progress) Snapshot() ProgressEvent {
+	if p == nil {
+		return ProgressEvent{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ev
+}
+
+func notify(subs []func(ProgressEvent), ev ProgressEvent) {
+	for _, fn := range subs {
+		fn(ev)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package kernel
+
+import "errors"
+
+// BeginRestorePoint 非 Windows 平台没有系统还原
+func BeginRestorePoint(description string) (int64, error) {
+	return 0, errors.New("system restore is only available on Windows")
+}
+
+// EndRestorePoint 非 Windows 平台为无操作
+func EndRestorePoint(seq int64, cancel bool) error { return nil }
@@ -0,0 +1,62 @@
+//go:build windows
+
+package kernel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var procSRSetRestorePointW = syscall.NewLazyDLL("srclient.dll").NewProc("SRSetRestorePointW")
+
+// SRSetRestorePointW 相关常量（srrestoreptapi.h）
+const (
+	srApplicationInstall = 0
+	srCancelledOperation = 13
+	srBeginSystemChange  = 100
+	srEndSystemChange    = 101
+)
+
+// BeginRestorePoint 创建系统还原点（需要管理员权限），返回供 EndRestorePoint 使用的序号。
+// 系统还原被禁用或不允许时返回错误，调用方可记录警告后继续安装。
+func BeginRestorePoint(description string) (int64, error) {
+	return setRestorePoint(srBeginSystemChange, srApplicationInstall, 0, description)
+}
+
+// EndRestorePoint 结束还原点；cancel 为 true 时（安装失败）撤销该还原点
+func EndRestorePoint(seq int64, cancel bool) error {
+	typ := uint32(srApplicationInstall)
+	if cancel {
+		typ = srCancelledOperation
+	}
+	_, err := setRestorePoint(srEndSystemChange, typ, seq, "")
+	return err
+}
+
+func setRestorePoint(eventType, pointType uint32, seq int64, description string) (int64, error) {
+	if err := procSRSetRestorePointW.Find(); err != nil {
+		return 0, err
+	}
+	// RESTOREPOINTINFOW 与 STATEMGRSTATUS 均按 1 字节对齐：
+	// {DWORD dwEventType; DWORD dwRestorePtType; INT64 llSequenceNumber; WCHAR szDescription[256]}
+	var info [4 + 4 + 8 + 256*2]byte
+	binary.LittleEndian.PutUint32(info[0:], eventType)
+	binary.LittleEndian.PutUint32(info[4:], pointType)
+	binary.LittleEndian.PutUint64(info[8:], uint64(seq))
+	desc, _ := syscall.UTF16FromString(description)
+	if len(desc) > 256 {
+		desc = append(desc[:255], 0)
+	}
+	for i, c := range desc {
+		binary.LittleEndian.PutUint16(info[16+2*i:], c)
+	}
+	// {DWORD nStatus; INT64 llSequenceNumber}
+	var status [4 + 8]byte
+	ok, _, _ := procSRSetRestorePointW.Call(uintptr(unsafe.Pointer(&info[0])), uintptr(unsafe.Pointer(&status[0])))
+	if ok == 0 {
+		return 0, fmt.Errorf("SRSetRestorePointW: %w", syscall.Errno(binary.LittleEndian.Uint32(status[0:])))
+	}
+	return int64(binary.LittleEndian.Uint64(status[4:])), nil
+}
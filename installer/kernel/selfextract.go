@@ -0,0 +1,259 @@
+package kernel
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 自解压文件布局: [stub][archive][签名块（可选，见 signature.go）][尾部]
+//
+// 尾部（当前版本，TrailerSize 字节）:
+//
+//	[校验和 32（FlagChecksum，见 integrity.go）][archive 长度 uint64 LE][版本 uint8][标志 uint8][压缩格式 uint8][保留 5][TrailerMagic]
+//
+// 旧版尾部为 [archive 长度 uint64 LE][LegacyMagic]，不含版本与标志，签名块只能按内容识别；读取时仍兼容。
+const (
+	TrailerMagic   = "SFXTRAIL"
+	LegacyMagic    = "SFXMAGIC"
+	TrailerVersion = 2
+	TrailerSize    = 32 + 8 + 8 + 8 // 8 = len(TrailerMagic)
+)
+
+// 尾部标志位
+const (
+	FlagSigned   uint8 = 1 << 0 // 尾部之前有签名块
+	FlagChecksum uint8 = 1 << 1 // 校验和字段有效
+)
+
+// 归档压缩格式
+const (
+	CompressionGzip uint8 = 0 // tar.gz
+)
+
+// Trailer 生成追加在归档（及签名块）之后的尾部；checksum 仅在 flags 含 FlagChecksum 时有意义
+func Trailer(archiveLen int, flags uint8, checksum [32]byte) []byte {
+	buf := make([]byte, TrailerSize-len(TrailerMagic), TrailerSize)
+	copy(buf, checksum[:])
+	binary.LittleEndian.PutUint64(buf[32:], uint64(archiveLen))
+	buf[40] = TrailerVersion
+	buf[41] = flags
+	buf[42] = CompressionGzip
+	return append(buf, TrailerMagic...)
+}
+
+// ========== 自解压基础 ==========
+
+// ExtractSelf 从当前可执行文件末尾读取 CreateInstaller 追加的归档，并返回归档的 SHA-256。
+// 带完整性校验和的安装器只读一遍文件，校验、读取与计算哈希同时完成。
+func ExtractSelf() (archive []byte, sum [32]byte, err error) {
+	self, err := SelfPath()
+	if err != nil {
+		return nil, sum, err
+	}
+	return readEmbeddedArchive(self)
+}
+
+// SelfPath 返回当前可执行文件的真实路径。os.Executable 可能返回经过符号链接的路径
+// （如通过链接启动），这里解析链接，使文件名判断与读取归档都针对真实文件；解析失败时退回原路径。
+func SelfPath() (string, error) {
+	exe, err := executable()
+	if err != nil {
+		return "", err
+	}
+	return resolveLink(exe), nil
+}
+
+// executable 即 os.Executable，测试中替换为固定路径
+var executable = os.Executable
+
+func resolveLink(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return p
+}
+
+// TrailerSearchLimit 在文件末尾查找 Magic 的窗口大小。Authenticode 签名追加在尾部之后，
+// 多重签名或证书链较长时可能超过几十 KB，可按需调大。
+var TrailerSearchLimit int64 = 1 << 20
+
+// ReadEmbeddedArchive 从 path 指向的安装器末尾读取追加的归档（打包后校验也使用它）；
+// 带签名块时先校验签名，失败返回 ErrSignatureInvalid
+func ReadEmbeddedArchive(path string) ([]byte, error) {
+	archive, _, err := readEmbeddedArchive(path)
+	return archive, err
+}
+
+func readEmbeddedArchive(path string) ([]byte, [32]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, [32]byte{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, [32]byte{}, err
+	}
+	return parseTrailer(f, info.Size())
+}
+
+// parseTrailer 在大小为 fileSize 的安装器 f 中定位尾部，返回归档及其 SHA-256，与文件来源无关
+func parseTrailer(f io.ReaderAt, fileSize int64) (archive []byte, archiveSum [32]byte, err error) {
+	// 1. 读取末尾窗口
+	if fileSize < 8+int64(len(LegacyMagic)) {
+		return nil, archiveSum, fmt.Errorf("file too small")
+	}
+	readSize := TrailerSearchLimit
+	if readSize > fileSize {
+		readSize = fileSize
+	}
+	startOffset := fileSize - readSize
+	buf := make([]byte, readSize)
+	if _, err := f.ReadAt(buf, startOffset); err != nil {
+		return nil, archiveSum, err
+	}
+
+	// 2. 在窗口中倒序查找 Magic（当前与旧版两种）。签名数据或归档内容可能恰好包含 Magic 字节，
+	//    因此每个候选都要校验长度/偏移与归档头，不合格则继续向前查找。
+	end := len(buf)
+	var lastErr error
+	corrupted := false
+	for {
+		idx, legacy := lastMagic(buf[:end])
+		if idx == -1 {
+			// 没有任何候选通过校验；其中有校验和不符的当前版本尾部时，报告文件损坏
+			if corrupted {
+				return nil, archiveSum, ErrCorrupted
+			}
+			if lastErr != nil {
+				return nil, archiveSum, lastErr
+			}
+			return nil, archiveSum, fmt.Errorf("magic mismatch (signature not found in last %d bytes)", readSize)
+		}
+		end = idx
+
+		var archiveLen uint64
+		var archiveEndOffset int64
+		var sigBlock []byte
+		var archiveBuf []byte // 完整性校验时顺带读出的归档
+		if legacy {
+			// Magic 前 8 字节为归档长度；窗口起点处被截断的候选直接跳过
+			if idx < 8 {
+				continue
+			}
+			archiveLen = binary.LittleEndian.Uint64(buf[idx-8 : idx])
+			// 归档结束位置即长度字段在文件中的位置；若其前面是签名块，则再向前跳过签名块
+			archiveEndOffset = startOffset + int64(idx-8)
+			if archiveEndOffset >= SignatureBlockSize {
+				blk := make([]byte, SignatureBlockSize)
+				if _, err := f.ReadAt(blk, archiveEndOffset-SignatureBlockSize); err == nil && isSignatureBlock(blk) {
+					sigBlock = blk
+					archiveEndOffset -= SignatureBlockSize
+				}
+			}
+		} else {
+			head := idx - (TrailerSize - len(TrailerMagic))
+			if head < 0 {
+				continue
+			}
+			t := buf[head:idx]
+			if version := t[40]; version != TrailerVersion {
+				lastErr = fmt.Errorf("unsupported trailer version %d", version)
+				continue
+			}
+			if compression := t[42]; compression != CompressionGzip {
+				lastErr = fmt.Errorf("unsupported archive compression %d", compression)
+				continue
+			}
+			archiveLen = binary.LittleEndian.Uint64(t[32:40])
+			archiveEndOffset = startOffset + int64(head)
+			signed := t[41]&FlagSigned != 0
+			// 先做完整性校验：不符时记下并继续查找更早的候选（如旧版尾部，或归档内容中恰好出现的 Magic），
+			// 全部候选都不合格时报告文件损坏，而不是因归档头损坏被当作找不到尾部。
+			// 归档位置由尾部的长度与签名标志确定，校验的同一遍读取中顺带读出归档并计算其哈希
+			if t[41]&FlagChecksum != 0 {
+				start := archiveEndOffset - int64(archiveLen)
+				if signed {
+					start -= SignatureBlockSize
+				}
+				var capture []byte
+				if archiveLen > 0 && archiveLen <= uint64(archiveEndOffset) && start >= 0 {
+					capture = make([]byte, archiveLen)
+				}
+				sum, captureSum, captured, err := integrityHashCapture(f, archiveEndOffset, start, capture)
+				if err != nil {
+					return nil, archiveSum, err
+				}
+				if !bytes.Equal(sum[:], t[:32]) {
+					corrupted = true
+					continue
+				}
+				if captured {
+					archiveBuf, archiveSum = capture, captureSum
+				}
+			}
+			if signed {
+				blk := make([]byte, SignatureBlockSize)
+				if archiveEndOffset < SignatureBlockSize {
+					continue
+				}
+				if _, err := f.ReadAt(blk, archiveEndOffset-SignatureBlockSize); err != nil || !isSignatureBlock(blk) {
+					continue
+				}
+				sigBlock = blk
+				archiveEndOffset -= SignatureBlockSize
+			}
+		}
+		if archiveLen == 0 || archiveLen > uint64(archiveEndOffset) {
+			continue
+		}
+		archiveStartOffset := archiveEndOffset - int64(archiveLen)
+		if archiveBuf != nil {
+			if !looksLikeTarGz(bytes.NewReader(archiveBuf)) {
+				continue
+			}
+		} else {
+			// 3. 校验时未读出归档（如旧版尾部没有校验和）则单独读取
+			if !looksLikeTarGz(io.NewSectionReader(f, archiveStartOffset, int64(archiveLen))) {
+				continue
+			}
+			archiveBuf = make([]byte, archiveLen)
+			if _, err := f.ReadAt(archiveBuf, archiveStartOffset); err != nil {
+				return nil, archiveSum, err
+			}
+			archiveSum = sha256.Sum256(archiveBuf)
+		}
+		if err := verifyArchive(archiveBuf, sigBlock); err != nil {
+			return nil, archiveSum, err
+		}
+		return archiveBuf, archiveSum, nil
+	}
+}
+
+// lastMagic 返回 b 中最后一个尾部 Magic 的位置，legacy 表示其为旧版 Magic；未找到时返回 -1
+func lastMagic(b []byte) (idx int, legacy bool) {
+	idx = bytes.LastIndex(b, []byte(TrailerMagic))
+	if l := bytes.LastIndex(b, []byte(LegacyMagic)); l > idx {
+		return l, true
+	}
+	return idx, false
+}
+
+// looksLikeTarGz 通过解析 gzip 头与第一个 tar 头判断候选区间是否为有效归档
+func looksLikeTarGz(r io.Reader) bool {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return false
+	}
+	defer gzr.Close()
+	_, err = tar.NewReader(gzr).Next()
+	return err == nil
+}
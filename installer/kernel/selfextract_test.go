@@ -0,0 +1,190 @@
+package kernel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sfx 按打包器的布局拼出安装器：stub + 归档 + 当前版本尾部（含完整性校验和）
+func sfx(t *testing.T, stub, archive []byte) []byte {
+	t.Helper()
+	body := append(append([]byte(nil), stub...), archive...)
+	sum, err := IntegrityHash(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(body, Trailer(len(archive), FlagChecksum, sum)...)
+}
+
+// legacySFX 旧版布局：stub + 归档 + [长度][LegacyMagic]
+func legacySFX(stub, archive []byte) []byte {
+	b := append(append([]byte(nil), stub...), archive...)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(archive)))
+	return append(b, LegacyMagic...)
+}
+
+func testArchive(t *testing.T) []byte {
+	t.Helper()
+	archive, err := BuildTarGz(map[string][]byte{"meta.json": []byte(`{"productName":"Demo"}`), "Demo.exe": []byte("exe")}, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestParseTrailer(t *testing.T) {
+	stub := bytes.Repeat([]byte("stub"), 64)
+	archive := testArchive(t)
+
+	corrupt := sfx(t, stub, archive)
+	corrupt[10] ^= 0xff
+
+	// 当前版本尾部校验和不符，但其前面是完整的旧版安装器：应退回旧版尾部
+	badSum := append(legacySFX(stub, archive), Trailer(len(archive), FlagChecksum, [32]byte{1})...)
+
+	// 不压缩的归档中直接出现两种 Magic；stub 本身也会含有这些字符串常量
+	magics := []byte("..." + TrailerMagic + "..." + LegacyMagic + "...")
+	falsePositive, err := BuildTarGz(map[string][]byte{"meta.json": []byte(`{}`), "magic.txt": magics}, gzip.NoCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	magicStub := append(append([]byte(nil), stub...), magics...)
+
+	full := sfx(t, stub, archive)
+	hugeLen := binary.LittleEndian.AppendUint64(append([]byte(nil), stub...), 1<<40)
+
+	tests := []struct {
+		name    string
+		file    []byte
+		want    []byte // 应读出的归档，nil 表示应失败
+		wantErr error  // 失败时期望的错误，nil 表示任意错误
+	}{
+		{"current trailer", full, archive, nil},
+		{"legacy trailer", legacySFX(stub, archive), archive, nil},
+		{"corrupted stub", corrupt, nil, ErrCorrupted},
+		{"falls back past a bad checksum", badSum, archive, nil},
+		{"magic inside the payload", sfx(t, stub, falsePositive), falsePositive, nil},
+		{"magic inside a legacy payload", legacySFX(stub, falsePositive), falsePositive, nil},
+		{"magic inside the stub", sfx(t, magicStub, archive), archive, nil},
+		{"stub without a trailer", magicStub, nil, nil},
+		{"truncated trailer", full[:len(full)-3], nil, nil},
+		{"truncated archive", full[:len(stub)+len(archive)/2], nil, nil},
+		{"archive length past the start", append(hugeLen, LegacyMagic...), nil, nil},
+		{"too small", []byte("MZ"), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sum, err := parseTrailer(bytes.NewReader(tt.file), int64(len(tt.file)))
+			if tt.want == nil {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("parseTrailer() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTrailer() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Error("parseTrailer() returned a different archive")
+			}
+			if sum != sha256.Sum256(tt.want) {
+				t.Error("parseTrailer() returned a wrong archive hash")
+			}
+		})
+	}
+}
+
+func TestSelfPathThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "uninstall.exe")
+	archive := testArchive(t)
+	if err := os.WriteFile(target, sfx(t, []byte("stub"), archive), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "launcher")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	got := resolveLink(link)
+	if filepath.Base(got) != "uninstall.exe" {
+		t.Errorf("resolveLink(%q) = %q, want the uninstall.exe target", link, got)
+	}
+	data, err := ReadEmbeddedArchive(got)
+	if err != nil {
+		t.Fatalf("ReadEmbeddedArchive() error = %v", err)
+	}
+	if !bytes.Equal(data, archive) {
+		t.Error("ReadEmbeddedArchive() through a symlink returned a different archive")
+	}
+	if missing := filepath.Join(dir, "missing"); resolveLink(missing) != missing {
+		t.Error("resolveLink() should return the original path when it cannot be resolved")
+	}
+}
+
+// Authenticode 签名追加在尾部之后：向后查找须越过这些字节，且只在 TrailerSearchLimit 窗口内查找
+func TestParseTrailerWithTrailingSignature(t *testing.T) {
+	stub := bytes.Repeat([]byte("stub"), 64)
+	archive := testArchive(t)
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(rng.Uint32())
+		}
+		return b
+	}
+	withSig := func(file []byte, sig ...[]byte) []byte {
+		out := append([]byte(nil), file...)
+		for _, s := range sig {
+			out = append(out, s...)
+		}
+		return out
+	}
+	full := sfx(t, stub, archive)
+	// 签名数据中偶然出现的 Magic：前面的字节被当作尾部解析，长度与校验和都不成立
+	stray := withSig(random(100), []byte(TrailerMagic), random(60), []byte(LegacyMagic), random(40))
+
+	tests := []struct {
+		name  string
+		file  []byte
+		limit int64 // TrailerSearchLimit，0 表示默认
+		ok    bool
+	}{
+		{"random signature", withSig(full, random(8<<10)), 0, true},
+		{"signature with stray magic", withSig(full, stray), 0, true},
+		{"stray magic at the very end", withSig(full, random(16), []byte(TrailerMagic)), 0, true},
+		{"legacy trailer with signature", withSig(legacySFX(stub, archive), stray, random(4<<10)), 0, true},
+		{"signature inside a reduced window", withSig(full, random(2<<10)), 4 << 10, true},
+		{"signature larger than the window", withSig(full, random(8<<10)), 4 << 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.limit != 0 {
+				old := TrailerSearchLimit
+				TrailerSearchLimit = tt.limit
+				t.Cleanup(func() { TrailerSearchLimit = old })
+			}
+			got, _, err := parseTrailer(bytes.NewReader(tt.file), int64(len(tt.file)))
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("parseTrailer() should not find a trailer outside TrailerSearchLimit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTrailer() error = %v", err)
+			}
+			if !bytes.Equal(got, archive) {
+				t.Error("parseTrailer() returned a different archive")
+			}
+		})
+	}
+}
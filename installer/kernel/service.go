@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// 服务启动类型（InstallMeta.ServiceStartType）
+const (
+	ServiceStartAuto     = "auto"     // 随系统自动启动
+	ServiceStartManual   = "manual"   // 手动启动（默认）
+	ServiceStartDisabled = "disabled" // 已禁用
+)
+
+// HasService 是否需要登记 Windows 服务
+func (m InstallMeta) HasService() bool { return m.ServiceName != "" }
+
+// ServiceBinary 返回服务程序的绝对路径：ServiceExePath 相对安装目录，为空时使用主程序
+func (m InstallMeta) ServiceBinary(installDir, exePath string) string {
+	if m.ServiceExePath == "" {
+		return exePath
+	}
+	if filepath.IsAbs(m.ServiceExePath) {
+		return m.ServiceExePath
+	}
+	return filepath.Join(installDir, m.ServiceExePath)
+}
+
+func validServiceStartType(s string) error {
+	switch s {
+	case "", ServiceStartAuto, ServiceStartManual, ServiceStartDisabled:
+		return nil
+	}
+	return fmt.Errorf("invalid service start type %q", s)
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package kernel
+
+// InstallService 在非 Windows 平台为无操作
+func InstallService(meta InstallMeta, installDir, exePath string) error {
+	return nil
+}
+
+// StopService 在非 Windows 平台为无操作
+func StopService(name string) error { return nil }
+
+// RemoveService 在非 Windows 平台为无操作
+func RemoveService(name string) error { return nil }
+
+// LoadServiceName 在非 Windows 平台返回空字符串
+func LoadServiceName(productName string) string { return "" }
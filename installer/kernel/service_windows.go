@@ -0,0 +1,153 @@
+//go:build windows
+
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceStopTimeout 等待服务停止的最长时间
+const serviceStopTimeout = 30 * time.Second
+
+// InstallService 通过服务控制管理器登记 meta 中描述的服务（需要管理员权限）。
+// 服务已存在时（升级安装）更新其程序路径、显示名称与启动类型。只登记不启动。
+func InstallService(meta InstallMeta, installDir, exePath string) error {
+	if !meta.HasService() {
+		return nil
+	}
+	if err := validServiceStartType(meta.ServiceStartType); err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	binary := `"` + meta.ServiceBinary(installDir, exePath) + `"`
+	display := meta.ServiceDisplayName
+	if display == "" {
+		display = meta.ServiceName
+	}
+	startType := uint32(mgr.StartManual)
+	switch meta.ServiceStartType {
+	case ServiceStartAuto:
+		startType = mgr.StartAutomatic
+	case ServiceStartDisabled:
+		startType = mgr.StartDisabled
+	}
+
+	if s, err := m.OpenService(meta.ServiceName); err == nil {
+		defer s.Close()
+		c, err := s.Config()
+		if err != nil {
+			return fmt.Errorf("query service %s: %w", meta.ServiceName, err)
+		}
+		c.BinaryPathName, c.DisplayName, c.StartType = binary, display, startType
+		if err := s.UpdateConfig(c); err != nil {
+			return fmt.Errorf("update service %s: %w", meta.ServiceName, err)
+		}
+		return nil
+	}
+	// CreateService 会给路径加引号，这里传原始路径
+	s, err := m.CreateService(meta.ServiceName, meta.ServiceBinary(installDir, exePath), mgr.Config{
+		DisplayName: display,
+		StartType:   startType,
+	})
+	if err != nil {
+		return fmt.Errorf("create service %s: %w", meta.ServiceName, err)
+	}
+	return s.Close()
+}
+
+// StopService 停止服务并等待其进入已停止状态；服务不存在或未运行时直接返回
+func StopService(name string) error {
+	if name == "" {
+		return nil
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil
+		}
+		return fmt.Errorf("open service %s: %w", name, err)
+	}
+	defer s.Close()
+	return stopService(s)
+}
+
+func stopService(s *mgr.Service) error {
+	st, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("query service %s: %w", s.Name, err)
+	}
+	if st.State == svc.Stopped {
+		return nil
+	}
+	if st.State != svc.StopPending {
+		if st, err = s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("stop service %s: %w", s.Name, err)
+		}
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for st.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not stop within %s", s.Name, serviceStopTimeout)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if st, err = s.Query(); err != nil {
+			return fmt.Errorf("query service %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// RemoveService 停止并删除服务；服务不存在时直接返回
+func RemoveService(name string) error {
+	if name == "" {
+		return nil
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil
+		}
+		return fmt.Errorf("open service %s: %w", name, err)
+	}
+	defer s.Close()
+	stopErr := stopService(s)
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("delete service %s: %w", name, err)
+	}
+	// 已标记删除；未能停止时服务会在进程退出后才真正移除
+	return stopErr
+}
+
+// LoadServiceName 读取安装时记录在基础键中的服务名，供卸载使用
+func LoadServiceName(productName string) string {
+	root := registryRoot(InstalledPerMachine(productName))
+	k, err := registry.OpenKey(root, `Software\\`+productName, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer k.Close()
+	v, _, _ := k.GetStringValue("ServiceName")
+	return v
+}
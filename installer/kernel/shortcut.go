@@ -0,0 +1,127 @@
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 快捷方式位置
+const (
+	ShortcutDesktop   = "desktop"
+	ShortcutStartMenu = "startmenu"
+)
+
+// ShortcutSpec 描述一个快捷方式
+type ShortcutSpec struct {
+	Name     string `json:"name"`               // 显示名称，为空时使用 ShortcutName / ProductName
+	Target   string `json:"target,omitempty"`   // 目标：相对安装目录的路径、绝对路径或 http(s) 网址；为空时指向主程序
+	Args     string `json:"args,omitempty"`     // 启动参数（网址快捷方式忽略）
+	Icon     string `json:"icon,omitempty"`     // 图标文件（相对安装目录或绝对路径），为空时使用目标本身
+	Location string `json:"location,omitempty"` // ShortcutDesktop 或 ShortcutStartMenu（默认）
+}
+
+// CreatedShortcuts 记录 CreateShortcuts 实际创建的内容，写入安装清单供卸载删除
+type CreatedShortcuts struct {
+	Links           []string // 快捷方式文件
+	StartMenuFolder string   // 开始菜单程序文件夹，未创建开始菜单快捷方式时为空
+}
+
+// IsURL 报告快捷方式目标是否为网址（生成 .url 而不是 .lnk）
+func (s ShortcutSpec) IsURL() bool {
+	t := strings.ToLower(s.Target)
+	return strings.HasPrefix(t, "http://") || strings.HasPrefix(t, "https://")
+}
+
+// isShortcutFile 报告 link 是否为位于 roots 中某个目录之内的 .lnk / .url 文件。
+// 卸载时据此校验安装清单中记录的快捷方式，防止清单被改写后删除其他文件
+func isShortcutFile(link string, roots ...string) bool {
+	ext := strings.ToLower(filepath.Ext(link))
+	if ext != ".lnk" && ext != ".url" {
+		return false
+	}
+	for _, r := range roots {
+		if r != "" && isWithin(filepath.Clean(link), filepath.Clean(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShortcutSpecs 返回需要创建的快捷方式，路径均已解析为绝对路径。meta.Shortcuts 非空时以它为准；
+// 否则按旧的 CreateDesktopShortcut / CreateStartMenuShortcut 开关生成指向 exePath 的快捷方式。
+// 有开始菜单快捷方式且安装目录中已有 uninstall.exe 时，追加一个“卸载 ProductName”快捷方式。
+func ShortcutSpecs(meta InstallMeta, installDir, exePath string) []ShortcutSpec {
+	specs := meta.Shortcuts
+	if len(specs) == 0 {
+		if meta.CreateDesktopShortcut {
+			specs = append(specs, ShortcutSpec{Location: ShortcutDesktop})
+		}
+		if meta.CreateStartMenuShortcut {
+			specs = append(specs, ShortcutSpec{Location: ShortcutStartMenu})
+		}
+	}
+
+	resolved := make([]ShortcutSpec, 0, len(specs))
+	for _, s := range specs {
+		if s.Name == "" {
+			s.Name = meta.DisplayShortcutName()
+		}
+		if s.Location == "" {
+			s.Location = ShortcutStartMenu
+		}
+		if s.Target == "" {
+			s.Target = exePath
+		} else if !s.IsURL() && !filepath.IsAbs(s.Target) {
+			s.Target = filepath.Join(installDir, filepath.FromSlash(s.Target))
+		}
+		if s.Icon != "" && !filepath.IsAbs(s.Icon) {
+			s.Icon = filepath.Join(installDir, filepath.FromSlash(s.Icon))
+		}
+		resolved = append(resolved, s)
+	}
+
+	for _, s := range resolved {
+		if s.Location != ShortcutStartMenu {
+			continue
+		}
+		uninstaller := filepath.Join(installDir, "uninstall.exe")
+		if _, err := os.Stat(uninstaller); err == nil {
+			resolved = append(resolved, ShortcutSpec{
+				Name:     T("uninstallShortcut", meta.ProductName),
+				Target:   uninstaller,
+				Location: ShortcutStartMenu,
+			})
+		}
+		break
+	}
+	return resolved
+}
+
+// StartMenuFolderName 返回开始菜单程序文件夹名称：StartMenuFolder，为空时为 ProductName
+func (m InstallMeta) StartMenuFolderName() string {
+	if m.StartMenuFolder != "" {
+		return m.StartMenuFolder
+	}
+	return m.ProductName
+}
+
+// DisplayShortcutName 返回默认快捷方式名称：ShortcutName，为空时为 ProductName
+func (m InstallMeta) DisplayShortcutName() string {
+	if m.ShortcutName != "" {
+		return m.ShortcutName
+	}
+	return m.ProductName
+}
+
+// writeURLShortcut 写入 Internet 快捷方式（.url）
+func writeURLShortcut(path, url, icon string) error {
+	content := "[InternetShortcut]\r\nURL=" + url + "\r\n"
+	if icon != "" {
+		content += "IconFile=" + icon + "\r\nIconIndex=0\r\n"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
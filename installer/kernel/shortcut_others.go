@@ -0,0 +1,8 @@
+//go:build !windows
+
+package kernel
+
+// CreateShortcuts 非 Windows 平台占位实现
+func CreateShortcuts(meta InstallMeta, installDir, exePath string) (CreatedShortcuts, error) {
+	return CreatedShortcuts{}, nil
+}
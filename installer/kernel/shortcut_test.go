@@ -0,0 +1,31 @@
+package kernel
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsShortcutFile(t *testing.T) {
+	root := t.TempDir()
+	programs := filepath.Join(root, "Start Menu", "Programs")
+	desktop := filepath.Join(root, "Desktop")
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{filepath.Join(programs, "Demo", "Demo.lnk"), true},
+		{filepath.Join(programs, "Demo", "Website.URL"), true},
+		{filepath.Join(desktop, "Demo.lnk"), true},
+		{filepath.Join(desktop, "Demo.exe"), false},            // 不是快捷方式
+		{filepath.Join(root, "Documents", "Demo.lnk"), false},  // 不在开始菜单或桌面
+		{filepath.Join(desktop, "..", "secret.lnk"), false},    // 经 .. 跳出
+		{filepath.Join(programs, "..", "Programs.lnk"), false}, // Programs 的上级
+		{desktop + ".lnk", false},                              // 前缀相同但不在目录内
+		{"Demo.lnk", false},                                    // 相对路径
+	}
+	for _, tt := range tests {
+		if got := isShortcutFile(tt.link, programs, desktop); got != tt.want {
+			t.Errorf("isShortcutFile(%q) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+}
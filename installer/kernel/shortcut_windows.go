@@ -1,6 +1,6 @@
 //go:build windows
 
-package main
+package kernel
 
 import (
 	"errors"
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime"
 	"strings"
 	"syscall"
 	"time"
@@ -19,65 +20,104 @@ import (
 	"github.com/go-ole/go-ole/oleutil"
 )
 
-func createShortcuts(targetExe, workingDir string, meta InstallMeta) error {
-	if _, err := os.Stat(targetExe); err != nil {
-		return fmt.Errorf("target exe missing: %w", err)
-	}
-
-	if workingDir == "" {
-		workingDir = filepath.Dir(targetExe)
+// CreateShortcuts 创建 ShortcutSpecs 给出的全部快捷方式：桌面快捷方式放在桌面，开始菜单快捷方式
+// 归入 Programs\<StartMenuFolder> 文件夹。返回已创建的内容（供卸载删除），错误汇总后返回。
+func CreateShortcuts(meta InstallMeta, installDir, exePath string) (CreatedShortcuts, error) {
+	var created CreatedShortcuts
+	// COM 初始化是线程级的：锁定 OS 线程，保证初始化与后续调用发生在同一线程上
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	uninit, err := initCOM()
+	if err != nil {
+		return created, err
 	}
+	defer uninit()
 
-	iconPath := targetExe
 	var errs []string
-
-	name := meta.ShortcutName
-	if name == "" {
-		name = meta.ProductName
-	}
-	name = sanitizeFilename(name)
-
-	if meta.CreateDesktopShortcut {
-		fmt.Println(" - 正在创建桌面快捷方式...")
-		if p, err := desktopDir(); err == nil {
-			link := filepath.Join(p, name+".lnk")
-			if err2 := createShortcut(link, targetExe, workingDir, iconPath); err2 != nil {
-				errs = append(errs, "Desktop:"+err2.Error())
-				fmt.Printf("   × 桌面快捷方式失败: %v\n", err2)
-			} else {
-				fmt.Printf("   √ 桌面快捷方式: %s\n", link)
-			}
+	for _, s := range ShortcutSpecs(meta, installDir, exePath) {
+		desktop := s.Location == ShortcutDesktop
+		var dir string
+		var err error
+		if desktop {
+			Log.Info(T("desktopShortcut"))
+			dir, err = DesktopDir(meta.PerMachine())
 		} else {
-			errs = append(errs, "DesktopDir:"+err.Error())
+			Log.Info(T("startMenuShortcut"))
+			dir, err = startMenuDir(sanitizeFilename(meta.StartMenuFolderName()), meta.PerMachine())
+		}
+		if err != nil {
+			errs = append(errs, s.Location+" dir:"+err.Error())
+			continue
+		}
+		link, err := createSpec(dir, s)
+		switch {
+		case err != nil && desktop:
+			errs = append(errs, "Desktop:"+err.Error())
+			Log.Warn(T("desktopShortcutFail", err))
+		case err != nil:
+			errs = append(errs, "StartMenu:"+err.Error())
+			Log.Warn(T("startMenuShortcutErr", err))
+		case desktop:
+			created.Links = append(created.Links, link)
+			Log.Info(T("desktopShortcutOK", link))
+		default:
+			created.Links = append(created.Links, link)
+			created.StartMenuFolder = dir
+			Log.Info(T("startMenuShortcutOK", link))
 		}
 	}
 
-	if meta.CreateStartMenuShortcut {
-		fmt.Println(" - 正在创建开始菜单快捷方式...")
-		if p, err := startMenuDir(name); err == nil {
-			if err = os.MkdirAll(p, 0o755); err != nil {
-				errs = append(errs, "StartMenu mkdir:"+err.Error())
-			} else {
-				link := filepath.Join(p, name+".lnk")
-				if err2 := createShortcut(link, targetExe, workingDir, iconPath); err2 != nil {
-					errs = append(errs, "StartMenu:"+err2.Error())
-					fmt.Printf("   × 开始菜单快捷方式失败: %v\n", err2)
-				} else {
-					fmt.Printf("   √ 开始菜单快捷方式: %s\n", link)
-				}
-			}
-		} else {
-			errs = append(errs, "StartMenuDir:"+err.Error())
+	if len(errs) > 0 {
+		return created, errors.New(strings.Join(errs, "; "))
+	}
+	return created, nil
+}
+
+// COM 返回码
+const (
+	sFalse          = 0x00000001 // 本线程已初始化过（仍需配对 CoUninitialize）
+	rpcEChangedMode = 0x80010106 // 本线程已以其他并发模型初始化，可直接使用，但不能由我们释放
+)
+
+// initCOM 在当前线程以 STA 初始化 COM，返回配对的释放函数
+func initCOM() (func(), error) {
+	err := ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED)
+	var oleErr *ole.OleError
+	if err != nil && errors.As(err, &oleErr) {
+		switch uint32(oleErr.Code()) {
+		case sFalse:
+			err = nil
+		case rpcEChangedMode:
+			return func() {}, nil
 		}
 	}
+	if err != nil {
+		return nil, fmt.Errorf("CoInitializeEx: %w", err)
+	}
+	return ole.CoUninitialize, nil
+}
 
-	if len(errs) > 0 {
-		return fmt.Errorf(strings.Join(errs, "; "))
+// createSpec 在 dir 中创建单个快捷方式：网址生成 .url，其余生成 .lnk
+func createSpec(dir string, s ShortcutSpec) (string, error) {
+	name := sanitizeFilename(s.Name)
+	if s.IsURL() {
+		link := filepath.Join(dir, name+".url")
+		return link, writeURLShortcut(link, s.Target, s.Icon)
 	}
-	return nil
+	if _, err := os.Stat(s.Target); err != nil {
+		return "", fmt.Errorf("target missing: %w", err)
+	}
+	link := filepath.Join(dir, name+".lnk")
+	return link, createShortcut(link, s.Target, s.Args, filepath.Dir(s.Target), s.Icon)
 }
 
-func desktopDir() (string, error) {
+// DesktopDir 返回桌面目录：全部用户安装使用公共桌面（%PUBLIC%\Desktop），否则为当前用户桌面
+func DesktopDir(perMachine bool) (string, error) {
+	if perMachine {
+		if pub := os.Getenv("PUBLIC"); pub != "" {
+			return filepath.Join(pub, "Desktop"), nil
+		}
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -85,15 +125,28 @@ func desktopDir() (string, error) {
 	return filepath.Join(home, "Desktop"), nil
 }
 
-func startMenuDir(product string) (string, error) {
-	appData := os.Getenv("AppData")
-	if appData == "" {
-		return "", fmt.Errorf("AppData env empty")
+// StartMenuProgramsDir 返回开始菜单 Programs 目录：全部用户安装位于 %ProgramData%，否则位于 %AppData%
+func StartMenuProgramsDir(perMachine bool) (string, error) {
+	env := "AppData"
+	if perMachine {
+		env = "ProgramData"
+	}
+	base := os.Getenv(env)
+	if base == "" {
+		return "", fmt.Errorf("%s env empty", env)
+	}
+	return filepath.Join(base, "Microsoft", "Windows", "Start Menu", "Programs"), nil
+}
+
+func startMenuDir(product string, perMachine bool) (string, error) {
+	programs, err := StartMenuProgramsDir(perMachine)
+	if err != nil {
+		return "", err
 	}
-	return filepath.Join(appData, "Microsoft", "Windows", "Start Menu", "Programs", product), nil
+	return filepath.Join(programs, product), nil
 }
 
-func createShortcut(linkPath, targetPath, workingDir, iconPath string) error {
+func createShortcut(linkPath, targetPath, args, workingDir, iconPath string) error {
 	if err := os.MkdirAll(filepath.Dir(linkPath), 0o755); err != nil {
 		return err
 	}
@@ -105,52 +158,52 @@ func createShortcut(linkPath, targetPath, workingDir, iconPath string) error {
 	}
 
 	// 优先使用底层 ShellLink 接口（完全 Unicode）
-	if err := createShortcutShellLinkLowLevel(linkPath, targetPath, workingDir, iconPath); err == nil {
+	if err := createShortcutShellLinkLowLevel(linkPath, targetPath, args, workingDir, iconPath); err == nil {
 		return nil
 	}
 
-	// 回退：使用 WScript.Shell + IDispatch + 最终 VBScript 双层回退
-	_ = ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED)
-	defer ole.CoUninitialize()
-
+	// 回退：使用 WScript.Shell + IDispatch + 最终 VBScript 双层回退（COM 已由 CreateShortcuts 初始化）
 	unknown, err := oleutil.CreateObject("WScript.Shell")
 	if err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("CreateObject: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("CreateObject: %w", err))
 	}
 	defer unknown.Release()
 	shell, err := unknown.QueryInterface(ole.IID_IDispatch)
 	if err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("QI: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("QI: %w", err))
 	}
 	defer shell.Release()
 
 	shortcutDisp, err := oleutil.CallMethod(shell, "CreateShortcut", linkPath)
 	if err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("CreateShortcut: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("CreateShortcut: %w", err))
 	}
 	shortcut := shortcutDisp.ToIDispatch()
 	defer shortcut.Release()
 
 	// 设置属性
 	if _, err = oleutil.PutProperty(shortcut, "TargetPath", targetPath); err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("TargetPath: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("TargetPath: %w", err))
+	}
+	if _, err = oleutil.PutProperty(shortcut, "Arguments", args); err != nil {
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("Arguments: %w", err))
 	}
 	if _, err = oleutil.PutProperty(shortcut, "WorkingDirectory", workingDir); err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("WorkingDirectory: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("WorkingDirectory: %w", err))
 	}
 	if _, err = oleutil.PutProperty(shortcut, "IconLocation", iconPath); err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("IconLocation: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("IconLocation: %w", err))
 	}
 	if _, err = oleutil.PutProperty(shortcut, "WindowStyle", 1); err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("WindowStyle: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("WindowStyle: %w", err))
 	}
 
 	if _, err = oleutil.CallMethod(shortcut, "Save"); err != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("Save: %w", err))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("Save: %w", err))
 	}
 	// 验证文件是否真的创建（某些奇怪的 locale 下 Save 返回成功但文件不存在）
 	if _, statErr := os.Stat(linkPath); statErr != nil {
-		return fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath, fmt.Errorf("post-save missing: %w", statErr))
+		return fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath, fmt.Errorf("post-save missing: %w", statErr))
 	}
 	return nil
 }
@@ -228,11 +281,8 @@ type IPersistFileVtbl struct {
 	GetCurFile    uintptr
 }
 
-func createShortcutShellLinkLowLevel(linkPath, targetPath, workingDir, iconPath string) error {
-	// 初始化 COM (允许外部已初始化)
-	_ = ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED)
-	// 不使用 defer CoUninitialize() 以免与上层重复释放；由上层统一处理
-
+// createShortcutShellLinkLowLevel 要求调用线程已初始化 COM（见 initCOM）
+func createShortcutShellLinkLowLevel(linkPath, targetPath, args, workingDir, iconPath string) error {
 	var ppv unsafe.Pointer
 	hr, _, _ := syscall.Syscall6(procCoCreateInstance.Addr(), 5,
 		uintptr(unsafe.Pointer(&CLSID_ShellLink)), 0, uintptr(clsctxInprocServer),
@@ -253,6 +303,9 @@ func createShortcutShellLinkLowLevel(linkPath, targetPath, workingDir, iconPath
 	if err := slSetPath(shellLink, targetPath); err != nil {
 		return err
 	}
+	if err := slSetArguments(shellLink, args); err != nil {
+		return err
+	}
 	if err := slSetWorkingDir(shellLink, workingDir); err != nil {
 		return err
 	}
@@ -304,6 +357,14 @@ func slSetPath(sl *IShellLinkW, path string) error {
 	}
 	return nil
 }
+func slSetArguments(sl *IShellLinkW, args string) error {
+	w, _ := syscall.UTF16PtrFromString(args)
+	hr, _, _ := syscall.Syscall(sl.lpVtbl.SetArguments, 2, uintptr(unsafe.Pointer(sl)), uintptr(unsafe.Pointer(w)), 0)
+	if failed(hr) {
+		return fmt.Errorf("SetArguments hr=0x%x", hr)
+	}
+	return nil
+}
 func slSetWorkingDir(sl *IShellLinkW, dir string) error {
 	w, _ := syscall.UTF16PtrFromString(dir)
 	hr, _, _ := syscall.Syscall(sl.lpVtbl.SetWorkingDirectory, 2, uintptr(unsafe.Pointer(sl)), uintptr(unsafe.Pointer(w)), 0)
@@ -346,7 +407,7 @@ func comRelease(p unsafe.Pointer) {
 }
 
 // fallbackVbsShortcut 尝试使用临时 VBScript 创建快捷方式 (UTF-16 LE BOM) 以提升兼容性
-func fallbackVbsShortcut(linkPath, targetPath, workingDir, iconPath string, originalErr error) error {
+func fallbackVbsShortcut(linkPath, targetPath, args, workingDir, iconPath string, originalErr error) error {
 	// 若已经存在则不重复
 	if _, err := os.Stat(linkPath); err == nil {
 		return nil
@@ -357,11 +418,12 @@ Dim shell, lnk
 Set shell = CreateObject("WScript.Shell")
 Set lnk = shell.CreateShortcut(%q)
 lnk.TargetPath = %q
+lnk.Arguments = %q
 lnk.WorkingDirectory = %q
 lnk.IconLocation = %q
 lnk.WindowStyle = 1
 lnk.Save
-`, linkPath, targetPath, workingDir, iconPath)
+`, linkPath, targetPath, args, workingDir, iconPath)
 
 	tmpDir := os.TempDir()
 	name := fmt.Sprintf("shortcut_%d.vbs", time.Now().UnixNano())
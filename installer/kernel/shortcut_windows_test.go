@@ -0,0 +1,76 @@
+//go:build windows
+
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// readShortcutTarget 通过 WScript.Shell 读回 .lnk 的目标路径
+func readShortcutTarget(t *testing.T, link string) string {
+	t.Helper()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	uninit, err := initCOM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uninit()
+	shell, err := oleutil.CreateObject("WScript.Shell")
+	if err != nil {
+		t.Fatalf("CreateObject: %v", err)
+	}
+	defer shell.Release()
+	disp, err := shell.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disp.Release()
+	lnk := oleutil.MustCallMethod(disp, "CreateShortcut", link).ToIDispatch()
+	defer lnk.Release()
+	return oleutil.MustGetProperty(lnk, "TargetPath").ToString()
+}
+
+func TestCreateShortcuts(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("USERPROFILE", filepath.Join(root, "profile"))
+	t.Setenv("AppData", filepath.Join(root, "appdata"))
+	installDir := filepath.Join(root, "Demo")
+	exe := filepath.Join(installDir, "Demo.exe")
+	writeTree(t, installDir, map[string]string{"Demo.exe": "MZ"})
+	if err := os.MkdirAll(filepath.Join(root, "profile", "Desktop"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := InstallMeta{ProductName: "Demo", CreateDesktopShortcut: true, CreateStartMenuShortcut: true}
+	// 在新的 goroutine 中调用：CreateShortcuts 必须自行初始化 COM，不依赖调用方线程的状态
+	var created CreatedShortcuts
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		created, err = CreateShortcuts(meta, installDir, exe)
+	}()
+	<-done
+	if err != nil {
+		t.Fatalf("CreateShortcuts() error = %v", err)
+	}
+	if len(created.Links) != 2 {
+		t.Fatalf("CreateShortcuts() created %v, want a desktop and a start menu shortcut", created.Links)
+	}
+	for _, link := range created.Links {
+		if got := readShortcutTarget(t, link); !strings.EqualFold(got, exe) {
+			t.Errorf("%s target = %q, want %q", link, got, exe)
+		}
+	}
+	if !strings.HasPrefix(created.StartMenuFolder, filepath.Join(root, "appdata")) {
+		t.Errorf("StartMenuFolder = %q, want it under the temporary AppData", created.StartMenuFolder)
+	}
+}
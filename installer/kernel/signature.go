@@ -0,0 +1,72 @@
+package kernel
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// 签名块（可选）位于归档与尾部之间: [Ed25519 公钥 32][签名 64][SignatureMagic]
+// 尾部中的长度仍只计归档本身，未签名的安装器布局不变。
+const (
+	SignatureMagic     = "SFXSIGN1"
+	SignatureBlockSize = ed25519.PublicKeySize + ed25519.SignatureSize + 8 // 8 = len(SignatureMagic)
+)
+
+// ErrSignatureInvalid 归档签名校验失败（内容被篡改或签名密钥不受信任）
+var ErrSignatureInvalid = errors.New("archive signature invalid")
+
+// TrustedPublicKey 受信任的签名公钥（hex）。为空时只校验归档与其内嵌公钥是否匹配；
+// 非空时要求归档必须由该密钥签名，可在构建 stub 时通过
+// -ldflags "-X exe_installer/installer/kernel.TrustedPublicKey=<hex>" 固定。
+var TrustedPublicKey string
+
+// SignatureBlock 用 priv 对归档签名，返回需写在归档之后、尾部之前的签名块
+func SignatureBlock(archive []byte, priv ed25519.PrivateKey) []byte {
+	block := make([]byte, 0, SignatureBlockSize)
+	block = append(block, priv.Public().(ed25519.PublicKey)...)
+	block = append(block, ed25519.Sign(priv, archive)...)
+	return append(block, SignatureMagic...)
+}
+
+// SplitSignedArchive 将末尾附带签名块的归档拆为归档本身与签名块；未签名时 block 为 nil
+func SplitSignedArchive(b []byte) (archive, block []byte) {
+	if n := len(b) - SignatureBlockSize; n > 0 && isSignatureBlock(b[n:]) {
+		return b[:n], b[n:]
+	}
+	return b, nil
+}
+
+// isSignatureBlock 判断 b 是否以 SignatureMagic 结尾的签名块
+func isSignatureBlock(b []byte) bool {
+	return len(b) == SignatureBlockSize && bytes.Equal(b[SignatureBlockSize-len(SignatureMagic):], []byte(SignatureMagic))
+}
+
+// verifyArchive 校验归档签名。block 为 nil 表示未签名：仅在未设置 TrustedPublicKey 时放行
+func verifyArchive(archive, block []byte) error {
+	var trusted ed25519.PublicKey
+	if TrustedPublicKey != "" {
+		k, err := hex.DecodeString(TrustedPublicKey)
+		if err != nil || len(k) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid TrustedPublicKey")
+		}
+		trusted = k
+	}
+	if block == nil {
+		if trusted != nil {
+			return fmt.Errorf("%w: archive is not signed", ErrSignatureInvalid)
+		}
+		return nil
+	}
+	pub := ed25519.PublicKey(block[:ed25519.PublicKeySize])
+	sig := block[ed25519.PublicKeySize : ed25519.PublicKeySize+ed25519.SignatureSize]
+	if trusted != nil && !bytes.Equal(trusted, pub) {
+		return fmt.Errorf("%w: signed by an untrusted key", ErrSignatureInvalid)
+	}
+	if !ed25519.Verify(pub, archive, sig) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package kernel
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 流式解压：条目边解压边写入磁盘，内存中不保留文件内容，适合低内存机器上的大安装包。
+
+// ErrMetaNotFound 归档根目录下没有 meta.json（通常是打包错误），调用方可据此警告后使用默认值
+var ErrMetaNotFound = errors.New("meta.json not found in archive")
+
+// LoadArchiveMeta 只从归档中读取 meta.json 并解析到 meta。打包器将 meta.json 写在最前，
+// 读到即停止；找不到时 meta 保持不变并返回 ErrMetaNotFound。
+func LoadArchiveMeta(gzData []byte, limits ArchiveLimits, meta *InstallMeta) error {
+	found := false
+	err := walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		if name != "meta.json" || h.Typeflag != tar.TypeReg {
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_ = json.Unmarshal(data, meta)
+		found = true
+		return errStopWalk
+	})
+	if err == nil && !found {
+		err = ErrMetaNotFound
+	}
+	return err
+}
+
+// ScanArchiveStats 流式遍历归档，统计文件数（不含目录）与解压后总字节数，不保留内容
+func ScanArchiveStats(gzData []byte, limits ArchiveLimits) (count int, size int64, err error) {
+	err = walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		if h.Typeflag == tar.TypeReg {
+			count++
+			size += h.Size
+		}
+		return nil
+	})
+	return count, size, err
+}
+
+// ScanConflicts 与 ConflictingFiles 相同，但流式遍历归档，不保留内容
+func ScanConflicts(gzData []byte, dir string, limits ArchiveLimits) ([]string, error) {
+	var out []string
+	err := walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		if h.Typeflag != tar.TypeReg {
+			return nil
+		}
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			out = append(out, name)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ScanManifestEntries 流式遍历归档，计算各文件的清单条目（路径、大小、SHA-256），不保留内容
+func ScanManifestEntries(gzData []byte, limits ArchiveLimits) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		if h.Typeflag != tar.TypeReg {
+			return nil
+		}
+		sum := sha256.New()
+		n, err := io.Copy(sum, r)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ManifestEntry{Path: name, Size: n, SHA256: hex.EncodeToString(sum.Sum(nil))})
+		return nil
+	})
+	return entries, err
+}
+
+// StreamToDir 边解压边将归档写入 dir，返回写入文件的清单条目（用于 ManifestFor）。
+// 行为与 WriteFiles 一致；为统计进度总量，会先多遍历一遍归档（解压但不保留内容）。
+func StreamToDir(gzData []byte, dir string, limits ArchiveLimits, opts WriteOptions) ([]ManifestEntry, error) {
+	// 第一遍：统计条目数与总大小供进度使用；fail 策略同时检查冲突，避免写入一半才失败
+	count, total := 0, int64(0)
+	err := walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		count++
+		if h.Typeflag != tar.TypeReg {
+			return nil
+		}
+		total += h.Size
+		if opts.Overwrite == OverwriteFail {
+			return prepareDest(filepath.Join(dir, filepath.FromSlash(name)), OverwriteFail)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	progress := opts.Progress
+	progress.StartPhase(PhaseWrite, total, count)
+	var entries []ManifestEntry
+	err = walkTarGz(gzData, limits, func(h *tar.Header, name string, r io.Reader) error {
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		if h.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return err
+			}
+			progress.AddItem(dest, 0, true)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		if err := prepareDest(dest, opts.Overwrite); err != nil {
+			return err
+		}
+		entry, err := streamFile(dest, os.FileMode(h.Mode), r, opts.Sync)
+		if err != nil {
+			return err
+		}
+		if opts.PreserveTimestamps && !h.ModTime.IsZero() {
+			if err := os.Chtimes(dest, h.ModTime, h.ModTime); err != nil {
+				return err
+			}
+		}
+		entry.Path = name
+		entries = append(entries, entry)
+		progress.AddItem(dest, entry.Size, false)
+		return nil
+	})
+	if err == nil && opts.Sync {
+		err = syncDirs(dir)
+	}
+	return entries, err
+}
+
+// streamFile 将 r 写入 dest，同时计算大小与 SHA-256；sync 为 true 时关闭前 fsync
+func streamFile(dest string, mode os.FileMode, r io.Reader, sync bool) (ManifestEntry, error) {
+	if mode == 0 {
+		mode = 0o644
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("write %s: %w", dest, err)
+	}
+	return ManifestEntry{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
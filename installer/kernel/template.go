@@ -0,0 +1,115 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateSuffix 模板文件的后缀，渲染结果写入去掉该后缀的文件
+const TemplateSuffix = ".template"
+
+// TemplateData 模板可用的值，既可写作 {{InstallDir}}，也可写作 {{.InstallDir}}；
+// 只包含字符串，模板无法调用其他函数
+type TemplateData struct {
+	InstallDir  string
+	ExePath     string
+	ProductName string
+	Version     string
+	Publisher   string
+}
+
+// RenderTemplates 渲染安装目录中与 meta.TemplateFiles 匹配的 .template 文件（路径模式，语法同 path.Match），
+// 结果写入去掉 .template 后缀的文件并记入 m.Generated，模板文件本身保留。返回渲染的文件数；
+// 模板引用不存在的字段时报错，该文件不写入结果。结果文件为 .json 时，值按 JSON 字符串内容转义
+// （如路径中的 \ 与 "），模板中应写在引号内："dir": "{{InstallDir}}"。
+func RenderTemplates(installDir, exePath string, meta InstallMeta, m *Manifest) (int, error) {
+	if len(meta.TemplateFiles) == 0 {
+		return 0, nil
+	}
+	raw := TemplateData{
+		InstallDir:  installDir,
+		ExePath:     exePath,
+		ProductName: meta.ProductName,
+		Version:     meta.Version,
+		Publisher:   meta.Publisher,
+	}
+	n := 0
+	for _, e := range m.Files {
+		if !strings.HasSuffix(e.Path, TemplateSuffix) || !matchAny(e.Path, meta.TemplateFiles) {
+			continue
+		}
+		src := filepath.Join(installDir, filepath.FromSlash(e.Path))
+		text, err := os.ReadFile(src)
+		if err != nil {
+			return n, err
+		}
+		target := strings.TrimSuffix(e.Path, TemplateSuffix)
+		data := raw
+		if strings.EqualFold(path.Ext(target), ".json") {
+			data = data.escaped(jsonEscape)
+		}
+		tmpl, err := template.New(e.Path).Option("missingkey=error").Funcs(data.funcs()).Parse(string(text))
+		if err != nil {
+			return n, fmt.Errorf("parse template %s: %w", e.Path, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return n, fmt.Errorf("render template %s: %w", e.Path, err)
+		}
+		mode := os.FileMode(0o644)
+		if st, err := os.Stat(src); err == nil {
+			mode = st.Mode().Perm()
+		}
+		dest := filepath.Join(installDir, filepath.FromSlash(target))
+		_ = clearReadOnly(dest) // 上次安装可能已设为只读
+		if err := os.WriteFile(dest, out.Bytes(), mode); err != nil {
+			return n, err
+		}
+		m.Generated = append(m.Generated, target)
+		n++
+	}
+	return n, nil
+}
+
+// funcs 把每个值注册为同名的无参函数，使 {{InstallDir}} 与 {{.InstallDir}} 等价
+func (d TemplateData) funcs() template.FuncMap {
+	return template.FuncMap{
+		"InstallDir":  func() string { return d.InstallDir },
+		"ExePath":     func() string { return d.ExePath },
+		"ProductName": func() string { return d.ProductName },
+		"Version":     func() string { return d.Version },
+		"Publisher":   func() string { return d.Publisher },
+	}
+}
+
+// escaped 返回对每个值应用 esc 后的副本
+func (d TemplateData) escaped(esc func(string) string) TemplateData {
+	return TemplateData{
+		InstallDir:  esc(d.InstallDir),
+		ExePath:     esc(d.ExePath),
+		ProductName: esc(d.ProductName),
+		Version:     esc(d.Version),
+		Publisher:   esc(d.Publisher),
+	}
+}
+
+// jsonEscape 返回 s 作为 JSON 字符串内容（不含两侧引号）的转义形式
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+func matchAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package kernel
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	meta := InstallMeta{ProductName: "Demo", Version: "1.2.0", Publisher: `ACME "Tools"`, TemplateFiles: []string{"conf/*.template"}}
+	tests := []struct {
+		name    string
+		file    string // 归档内路径
+		text    string
+		want    string // 期望的结果内容，wantErr 时忽略
+		wantErr bool
+	}{
+		{
+			name: "function placeholders",
+			file: "conf/app.ini.template",
+			text: "dir = {{InstallDir}}\nversion = {{Version}}\n",
+			want: "dir = {{dir}}\nversion = 1.2.0\n",
+		},
+		{
+			name: "field placeholders",
+			file: "conf/app.ini.template",
+			text: "name={{.ProductName}} exe={{.ExePath}}",
+			want: "name=Demo exe={{exe}}",
+		},
+		{
+			name: "no placeholders",
+			file: "conf/plain.txt.template",
+			text: "nothing to replace\n",
+			want: "nothing to replace\n",
+		},
+		{
+			name: "json values are escaped",
+			file: "conf/settings.json.template",
+			text: `{"dir": "{{InstallDir}}", "publisher": "{{.Publisher}}"}`,
+		},
+		{
+			name:    "unknown field",
+			file:    "conf/bad.ini.template",
+			text:    "{{.Password}}",
+			wantErr: true,
+		},
+		{
+			name:    "unknown function",
+			file:    "conf/bad.ini.template",
+			text:    "{{Password}}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 路径中含 \ 与 "（Windows 路径、带引号的目录名），检验 JSON 转义
+			dir := filepath.Join(t.TempDir(), `Demo \ "x"`)
+			files := writeTree(t, dir, map[string]string{tt.file: tt.text, "Demo.exe": "exe"})
+			m := BuildManifest(meta, files)
+			exe := filepath.Join(dir, "Demo.exe")
+
+			n, err := RenderTemplates(dir, exe, meta, &m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			target := tt.file[:len(tt.file)-len(TemplateSuffix)]
+			out, readErr := os.ReadFile(filepath.Join(dir, filepath.FromSlash(target)))
+			if tt.wantErr {
+				if readErr == nil {
+					t.Errorf("%s was written despite the error", target)
+				}
+				return
+			}
+			if n != 1 || len(m.Generated) != 1 || m.Generated[0] != target {
+				t.Errorf("n = %d, Generated = %v, want 1 and [%s]", n, m.Generated, target)
+			}
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if filepath.Ext(target) == ".json" {
+				var got map[string]string
+				if err := json.Unmarshal(out, &got); err != nil {
+					t.Fatalf("rendered JSON is invalid: %v\n%s", err, out)
+				}
+				if got["dir"] != dir || got["publisher"] != meta.Publisher {
+					t.Errorf("rendered JSON = %v", got)
+				}
+				return
+			}
+			want := strings.NewReplacer("{{dir}}", dir, "{{exe}}", exe).Replace(tt.want)
+			if string(out) != want {
+				t.Errorf("rendered %q, want %q", out, want)
+			}
+			// 模板文件本身保留
+			if !exists(filepath.Join(dir, filepath.FromSlash(tt.file))) {
+				t.Error("template file was removed")
+			}
+		})
+	}
+}
+
+func TestRenderTemplatesSkipsUnlisted(t *testing.T) {
+	dir := t.TempDir()
+	meta := InstallMeta{TemplateFiles: []string{"conf/*.template"}}
+	m := BuildManifest(meta, writeTree(t, dir, map[string]string{"other/app.ini.template": "{{InstallDir}}"}))
+	if n, err := RenderTemplates(dir, "", meta, &m); err != nil || n != 0 {
+		t.Fatalf("RenderTemplates() = %d, %v; want 0, nil", n, err)
+	}
+	if exists(filepath.Join(dir, "other", "app.ini")) {
+		t.Error("a template outside TemplateFiles was rendered")
+	}
+}
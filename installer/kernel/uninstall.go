@@ -0,0 +1,95 @@
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UninstallOptions 控制 Uninstall
+type UninstallOptions struct {
+	// ProductName 注册表中的产品名；为空时使用安装清单中的产品名，再退回安装目录名
+	ProductName string
+	// KeepUserData 只删除安装清单记录的文件，安装后新建的文件视为用户数据保留。没有安装清单时无法区分，
+	// Uninstall 拒绝执行。两种方式都保留清单中的 PreserveDirs。
+	KeepUserData bool
+	// RemoveInstallDir 为 false 时保留安装目录本身及其中未被清单记录的文件（同 KeepUserData），
+	// 适用于安装到用户已有目录（如 C:\Tools）的情况，同样要求有安装清单；nil 表示 true
+	RemoveInstallDir *bool
+	// AllowAnyInstallDir 删除全部内容时不要求安装目录位于常规安装位置（见 cleanguard.go）；
+	// 安装清单中记录了 InstallMeta.AllowAnyInstallDir 时同样放行
+	AllowAnyInstallDir bool
+	// Skip 不删除的路径（如正在运行的卸载程序本身，由调用方稍后删除）
+	Skip []string
+	// Progress 逐个文件上报删除进度（PhaseRemove），可为 nil
+	Progress *Progress
+	// Cancel 关闭后停止删除后续文件，Uninstall 返回 ErrCancelled
+	Cancel <-chan struct{}
+}
+
+// ErrCancelled 操作被取消
+var ErrCancelled = errors.New("cancelled")
+
+// Uninstall 卸载 installDir 中的产品：停止并删除服务，删除安装的文件，再删除文件关联、快捷方式与注册表项；
+// 安装目录变空时一并删除（RemoveInstallDir 为 false 时保留）。各步骤相互独立，出错时继续执行其余步骤，最后返回汇总的错误。
+// 删除全部内容前与安装时清空目录一样做防误删检查（见 cleanguard.go），卷根、系统目录、用户目录等直接拒绝，
+// 常规安装位置之外的目录也拒绝；没有安装清单时还要求目录名包含产品名。
+func Uninstall(installDir string, opts UninstallOptions) error {
+	if _, err := os.Stat(installDir); err != nil {
+		return err
+	}
+	removeDir := opts.RemoveInstallDir == nil || *opts.RemoveInstallDir
+	m, manifestErr := ReadManifest(installDir)
+	if manifestErr != nil {
+		// 没有清单就无法区分安装的文件与用户文件，只能删除全部内容，与保留的要求矛盾
+		if opts.KeepUserData || !removeDir {
+			return fmt.Errorf("cannot keep user data in %s without an install manifest: %w", installDir, manifestErr)
+		}
+		m = Manifest{}
+	}
+	productName := opts.ProductName
+	if productName == "" {
+		productName = m.ProductName
+	}
+	if productName == "" {
+		productName = filepath.Base(installDir)
+	}
+	keepUserData := opts.KeepUserData || !removeDir
+	if !keepUserData {
+		anyDir := opts.AllowAnyInstallDir || m.AllowAnyInstallDir
+		guard := checkProtectedDir(installDir, anyDir)
+		if manifestErr != nil {
+			guard = checkCleanTarget(installDir, productName, anyDir)
+		}
+		if guard != nil {
+			return guard
+		}
+	}
+	perMachine := InstalledPerMachine(productName)
+
+	var errs []error
+	// 服务须先停止，服务程序才能被删除
+	if err := RemoveService(LoadServiceName(productName)); err != nil {
+		errs = append(errs, err)
+	}
+	// 先删除文件：中途取消时注册表项（“应用和功能”中的卸载入口）与安装清单仍在，可以再次卸载
+	if err := removeInstalledFiles(installDir, m, keepUserData, opts.Progress, opts.Cancel, opts.Skip); errors.Is(err, ErrCancelled) {
+		return err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	// 文件关联与快捷方式名称记录在注册表中，须在删除注册表之前读取
+	if err := UnregisterFileAssociations(LoadFileAssociations(productName)); err != nil {
+		errs = append(errs, fmt.Errorf("unregister file associations: %w", err))
+	}
+	removeShortcuts(m, productName, perMachine)
+	if err := DeleteRegistry(productName, perMachine); err != nil {
+		errs = append(errs, err)
+	}
+	if removeDir {
+		_ = os.Remove(installDir) // 只在已空时删除
+	}
+	return errors.Join(errs...)
+}
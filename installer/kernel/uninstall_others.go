@@ -0,0 +1,6 @@
+//go:build !windows
+
+package kernel
+
+// removeShortcuts 非 Windows 平台不创建快捷方式
+func removeShortcuts(m Manifest, productName string, perMachine bool) {}
@@ -0,0 +1,197 @@
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree 在 dir 下写入 files（归档内路径 -> 内容）
+func writeTree(t *testing.T, dir string, files map[string]string) []*InMemoryFile {
+	t.Helper()
+	var out []*InMemoryFile
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, &InMemoryFile{Name: name, Data: []byte(data)})
+	}
+	return out
+}
+
+// installFixture 模拟一次安装：写入 installed 并生成安装清单，再写入安装后新建的 user 文件
+func installFixture(t *testing.T, dir string, installed, user map[string]string, preserve []string) {
+	t.Helper()
+	files := writeTree(t, dir, installed)
+	m := BuildManifest(InstallMeta{ProductName: "Demo", PreserveDirs: preserve}, files)
+	if err := WriteManifest(dir, m); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, user)
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+func TestUninstall(t *testing.T) {
+	installed := map[string]string{"Demo.exe": "exe", "bin/lib.dll": "dll"}
+	user := map[string]string{"settings.ini": "user", "data/save.dat": "save"}
+	no := false
+
+	tests := []struct {
+		name        string
+		dirName     string
+		noManifest  bool
+		preserve    []string
+		opts        func(dir string) UninstallOptions
+		wantErr     bool
+		wantGone    []string // 应被删除的相对路径（"." 表示安装目录本身）
+		wantPresent []string // 应保留的相对路径
+	}{
+		{
+			name:     "manifest removes everything",
+			dirName:  "Demo",
+			opts:     func(string) UninstallOptions { return UninstallOptions{} },
+			wantGone: []string{"."},
+		},
+		{
+			name:        "manifest keep user data",
+			dirName:     "Demo",
+			opts:        func(string) UninstallOptions { return UninstallOptions{KeepUserData: true} },
+			wantGone:    []string{"Demo.exe", "bin", ManifestName},
+			wantPresent: []string{"settings.ini", "data/save.dat"},
+		},
+		{
+			name:        "manifest keep install dir",
+			dirName:     "Demo",
+			opts:        func(string) UninstallOptions { return UninstallOptions{RemoveInstallDir: &no} },
+			wantGone:    []string{"Demo.exe", "bin/lib.dll"},
+			wantPresent: []string{"settings.ini"},
+		},
+		{
+			name:        "preserve dirs survive full removal",
+			dirName:     "Demo",
+			preserve:    []string{"data"},
+			opts:        func(string) UninstallOptions { return UninstallOptions{} },
+			wantGone:    []string{"Demo.exe", "bin", "settings.ini", ManifestName},
+			wantPresent: []string{"data/save.dat"},
+		},
+		{
+			name:    "skip keeps the running uninstaller",
+			dirName: "Demo",
+			opts: func(dir string) UninstallOptions {
+				return UninstallOptions{Skip: []string{filepath.Join(dir, "Demo.exe")}}
+			},
+			wantGone:    []string{"bin", "settings.ini", ManifestName},
+			wantPresent: []string{"Demo.exe"},
+		},
+		{
+			name:       "no manifest removes everything",
+			dirName:    "Demo",
+			noManifest: true,
+			opts:       func(string) UninstallOptions { return UninstallOptions{ProductName: "Demo"} },
+			wantGone:   []string{"."},
+		},
+		{
+			name:        "no manifest refuses keep user data",
+			dirName:     "Demo",
+			noManifest:  true,
+			opts:        func(string) UninstallOptions { return UninstallOptions{KeepUserData: true} },
+			wantErr:     true,
+			wantPresent: []string{"Demo.exe", "settings.ini"},
+		},
+		{
+			name:        "no manifest refuses keep install dir",
+			dirName:     "Demo",
+			noManifest:  true,
+			opts:        func(string) UninstallOptions { return UninstallOptions{RemoveInstallDir: &no} },
+			wantErr:     true,
+			wantPresent: []string{"Demo.exe", "settings.ini"},
+		},
+		{
+			name:        "no manifest refuses dir without product name",
+			dirName:     "Tools",
+			noManifest:  true,
+			opts:        func(string) UninstallOptions { return UninstallOptions{ProductName: "Demo"} },
+			wantErr:     true,
+			wantPresent: []string{"Demo.exe", "settings.ini"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(installRoot(t), tt.dirName)
+			if tt.noManifest {
+				writeTree(t, dir, installed)
+				writeTree(t, dir, user)
+			} else {
+				installFixture(t, dir, installed, user, tt.preserve)
+			}
+			err := Uninstall(dir, tt.opts(dir))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Uninstall() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, p := range tt.wantGone {
+				if exists(filepath.Join(dir, filepath.FromSlash(p))) {
+					t.Errorf("%s should have been removed", p)
+				}
+			}
+			for _, p := range tt.wantPresent {
+				if !exists(filepath.Join(dir, filepath.FromSlash(p))) {
+					t.Errorf("%s should have been kept", p)
+				}
+			}
+		})
+	}
+}
+
+func TestUninstallRefusesProtectedDir(t *testing.T) {
+	// 以临时目录充当用户目录，防护失效时也只会删掉测试数据
+	home := filepath.Join(t.TempDir(), "home")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	installFixture(t, home, map[string]string{"Demo.exe": "exe"}, map[string]string{"notes.txt": "user"}, nil)
+
+	if err := Uninstall(home, UninstallOptions{ProductName: "Demo"}); err == nil {
+		t.Fatal("Uninstall() of the home directory should fail")
+	}
+	for _, p := range []string{"Demo.exe", "notes.txt", ManifestName} {
+		if !exists(filepath.Join(home, p)) {
+			t.Errorf("%s was removed from the home directory", p)
+		}
+	}
+}
+
+func TestUninstallOutsideInstallRoots(t *testing.T) {
+	installRoot(t)
+	dir := filepath.Join(t.TempDir(), "Demo")
+	installFixture(t, dir, map[string]string{"Demo.exe": "exe"}, map[string]string{"notes.txt": "user"}, nil)
+
+	if err := Uninstall(dir, UninstallOptions{ProductName: "Demo"}); err == nil {
+		t.Fatal("Uninstall() outside the install roots should fail")
+	}
+	if !exists(filepath.Join(dir, "notes.txt")) {
+		t.Error("notes.txt was removed by a refused uninstall")
+	}
+
+	// 打包时设置的 AllowAnyInstallDir 记录在安装清单中，卸载时放行
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AllowAnyInstallDir = true
+	if err := WriteManifest(dir, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninstall(dir, UninstallOptions{ProductName: "Demo"}); err != nil {
+		t.Fatalf("Uninstall() with AllowAnyInstallDir error = %v", err)
+	}
+	if exists(dir) {
+		t.Error("the install dir should have been removed")
+	}
+}
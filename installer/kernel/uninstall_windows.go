@@ -0,0 +1,47 @@
+//go:build windows
+
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// removeShortcuts 删除快捷方式：优先按清单记录删除各快捷方式与开始菜单程序文件夹，
+// 没有记录时（旧版本安装）按注册表中的 ShortcutName 推断
+func removeShortcuts(m Manifest, productName string, perMachine bool) {
+	programs, _ := StartMenuProgramsDir(perMachine)
+	desktop, _ := DesktopDir(perMachine)
+	if len(m.Shortcuts) > 0 {
+		// 只删除开始菜单 Programs 或桌面中的 .lnk / .url，防止清单被改写后误删其他文件
+		for _, link := range m.Shortcuts {
+			if isShortcutFile(link, programs, desktop) {
+				_ = os.Remove(link)
+			} else {
+				Log.Warn(T("shortcutSkipped", link))
+			}
+		}
+		// 只删除位于开始菜单 Programs 之下的文件夹，防止清单被改写后误删其他目录
+		if dir := m.StartMenuFolder; dir != "" && programs != "" && strings.HasPrefix(strings.ToLower(dir), strings.ToLower(programs)+`\`) {
+			_ = os.RemoveAll(dir)
+		}
+		return
+	}
+
+	shortcutName := productName
+	if k, err := registry.OpenKey(registryRoot(perMachine), `Software\\`+productName, registry.QUERY_VALUE); err == nil {
+		if v, _, err := k.GetStringValue("ShortcutName"); err == nil && v != "" {
+			shortcutName = v
+		}
+		k.Close()
+	}
+	if desktop != "" {
+		_ = os.Remove(filepath.Join(desktop, shortcutName+".lnk"))
+	}
+	if programs != "" {
+		_ = os.RemoveAll(filepath.Join(programs, shortcutName))
+	}
+}
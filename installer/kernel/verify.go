@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// VerifyTimeout 安装验证命令的最长运行时间
+var VerifyTimeout = 2 * time.Minute
+
+// RunVerifyCmd 在安装目录中通过系统 shell（Windows 为 cmd /C，其他平台为 sh -c）运行验证命令，
+// 环境变量 INSTALL_DIR、APP_EXE 分别为安装目录与主程序路径。返回合并的 stdout/stderr；
+// 退出码非 0 或超时视为验证失败。
+func RunVerifyCmd(command, installDir, exePath string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), VerifyTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = installDir
+	cmd.Env = append(os.Environ(), "INSTALL_DIR="+installDir, "APP_EXE="+exePath)
+	out, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return string(out), fmt.Errorf("timed out after %s", VerifyTimeout)
+	}
+	return string(out), err
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package kernel
+
+// WriteRegistry 在非 Windows 平台为无操作，以保持编译通过。
+func WriteRegistry(meta InstallMeta, installDir, exePath string, installedSize int64) error {
+	_ = meta
+	_ = installDir
+	_ = exePath
+	_ = installedSize
+	return nil
+}
+
+// InstalledPerMachine 非 Windows 平台没有注册表，恒为 false
+func InstalledPerMachine(productName string) bool { return false }
+
+// VerifyRegistry 非 Windows 平台为无操作
+func VerifyRegistry(meta InstallMeta, installDir string) error { return nil }
+
+// DeleteRegistry 非 Windows 平台为无操作
+func DeleteRegistry(productName string, perMachine bool) error { return nil }
@@ -0,0 +1,184 @@
+//go:build windows
+
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// WriteRegistry 写入安装与卸载信息到注册表（全部用户安装写 HKLM，当前用户安装写 HKCU）。
+// Keys:
+//  1. <Root>\Software\<ProductName> : InstallDir, ExePath, Version
+//  2. <Root>\Software\Microsoft\Windows\CurrentVersion\Uninstall\<ProductName>
+//     以便显示在“应用和功能”/“卸载程序”列表。仅在安装目录中已有 uninstall.exe 时写入，
+//     否则（如 InstallFromArchive 的无界面安装）卸载入口无法使用，不如不登记。
+//
+// installedSize 为安装写入的字节数（见 Manifest.InstalledSize），记为 EstimatedSize。
+func WriteRegistry(meta InstallMeta, installDir, exePath string, installedSize int64) error {
+	if meta.ProductName == "" {
+		return fmt.Errorf("empty product name")
+	}
+	if installDir == "" || exePath == "" {
+		return fmt.Errorf("empty paths")
+	}
+
+	root := registryRoot(meta.PerMachine())
+	basePath := `Software\\` + meta.ProductName
+	if err := setValues(root, basePath, map[string]any{
+		"InstallDir": installDir,
+		"ExePath":    exePath,
+		"Version":    meta.Version,
+		"ShortcutName": func() string {
+			if meta.ShortcutName != "" {
+				return meta.ShortcutName
+			}
+			return meta.ProductName
+		}(),
+	}); err != nil {
+		return fmt.Errorf("write base key: %w", err)
+	}
+	if len(meta.FileAssociations) > 0 {
+		if err := setValues(root, basePath, map[string]any{
+			"FileAssociations": encodeFileAssocs(meta.FileAssociations),
+		}); err != nil {
+			return fmt.Errorf("write base key: %w", err)
+		}
+	}
+	if meta.HasService() {
+		if err := setValues(root, basePath, map[string]any{"ServiceName": meta.ServiceName}); err != nil {
+			return fmt.Errorf("write base key: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(installDir, "uninstall.exe")); err != nil {
+		return nil
+	}
+	uninstallPath := `Software\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\` + meta.ProductName
+	uninstallString := uninstallCommand(installDir)
+	if err := setValues(root, uninstallPath, map[string]any{
+		"DisplayName":          meta.ProductName,
+		"DisplayVersion":       meta.Version,
+		"InstallLocation":      installDir,
+		"Publisher":            meta.Publisher,
+		"EstimatedSize":        uint32((installedSize + 1023) / 1024), // 以 KB 为单位
+		"UninstallString":      uninstallString,
+		"QuietUninstallString": uninstallString + " /S",
+		"DisplayIcon":          exePath + ",0",
+		"ModifyPath":           uninstallString + " --repair", // “修改”入口：按安装清单修复缺失或损坏的文件
+		"NoModify":             uint32(0),
+		"NoRepair":             uint32(1),
+		"InstallSource":        filepath.Dir(exePath),
+	}); err != nil {
+		return fmt.Errorf("write uninstall key: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyRegistry 回读卸载键，确认 DisplayName 与 UninstallString 与 WriteRegistry 写入的一致；
+// 键缺失时“设置 > 应用”中将无法卸载
+func VerifyRegistry(meta InstallMeta, installDir string) error {
+	uninstallPath := `Software\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\` + meta.ProductName
+	k, err := registry.OpenKey(registryRoot(meta.PerMachine()), uninstallPath, registry.QUERY_VALUE)
+	if err != nil {
+		return fmt.Errorf("open uninstall key: %w", err)
+	}
+	defer k.Close()
+	for name, want := range map[string]string{
+		"DisplayName":     meta.ProductName,
+		"UninstallString": uninstallCommand(installDir),
+	} {
+		got, _, err := k.GetStringValue(name)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		if got != want {
+			return fmt.Errorf("%s is %q, want %q", name, got, want)
+		}
+	}
+	return nil
+}
+
+// uninstallCommand 卸载键中登记的卸载命令。uninstall.exe 由调用方（stub）负责生成，这里只登记路径
+func uninstallCommand(installDir string) string {
+	return fmt.Sprintf("\"%s\"", filepath.Join(installDir, "uninstall.exe"))
+}
+
+// DeleteRegistry 删除 WriteRegistry 写入的基础键与卸载键（不存在时忽略），用于卸载与安装回滚
+func DeleteRegistry(productName string, perMachine bool) error {
+	root := registryRoot(perMachine)
+	var errs []string
+	for _, path := range []string{
+		`Software\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\` + productName,
+		`Software\\` + productName,
+	} {
+		if err := registry.DeleteKey(root, path); err != nil && err != registry.ErrNotExist {
+			errs = append(errs, path+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("delete registry: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// registryRoot 按安装范围选择注册表根
+func registryRoot(perMachine bool) registry.Key {
+	if perMachine {
+		return registry.LOCAL_MACHINE
+	}
+	return registry.CURRENT_USER
+}
+
+// InstalledPerMachine 报告产品是否以全部用户方式安装（基础键位于 HKLM）
+func InstalledPerMachine(productName string) bool {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `Software\\`+productName, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	k.Close()
+	return true
+}
+
+// errUnsupportedValue setValues 遇到无法映射为注册表类型的值
+var errUnsupportedValue = errors.New("unsupported registry value type")
+
+// setValues 在 root\path 下写入 kv（不存在则创建键）。string 写为 REG_SZ，uint32 写为 REG_DWORD，
+// []string 写为 REG_MULTI_SZ；出错时返回的错误指明失败的键与值，键在任何路径上都会关闭。
+func setValues(root registry.Key, path string, kv map[string]any) (err error) {
+	k, _, err := registry.CreateKey(root, path, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("create key %s: %w", path, err)
+	}
+	defer func() {
+		if cerr := k.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close key %s: %w", path, cerr)
+		}
+	}()
+	for name, v := range kv {
+		switch val := v.(type) {
+		case string:
+			err = k.SetStringValue(name, val)
+		case uint32:
+			err = k.SetDWordValue(name, val)
+		case []string:
+			err = k.SetStringsValue(name, val)
+		default:
+			err = fmt.Errorf("%w %T", errUnsupportedValue, v)
+		}
+		if err != nil {
+			return fmt.Errorf("set %s\\%s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// createUninstallScript 生成简单卸载脚本：删除注册表、快捷方式和安装目录。
+// 以下函数仅保留 sanitizePath 以防后续使用
+func sanitizePath(p string) string { return strings.Trim(p, "\"") }
@@ -0,0 +1,57 @@
+//go:build windows
+
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func TestSetValuesRoundTrip(t *testing.T) {
+	path := fmt.Sprintf(`Software\ExeInstallerTest%08x`, rand.Uint32())
+	t.Cleanup(func() {
+		if err := registry.DeleteKey(registry.CURRENT_USER, path); err != nil && err != registry.ErrNotExist {
+			t.Errorf("delete %s: %v", path, err)
+		}
+	})
+
+	err := setValues(registry.CURRENT_USER, path, map[string]any{
+		"DisplayName":   "Demo",
+		"EstimatedSize": uint32(12345),
+		"NoModify":      uint32(0),
+		"Extensions":    []string{".demo", ".dmo"},
+	})
+	if err != nil {
+		t.Fatalf("setValues() error = %v", err)
+	}
+
+	k, err := registry.OpenKey(registry.CURRENT_USER, path, registry.QUERY_VALUE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+	if s, typ, err := k.GetStringValue("DisplayName"); err != nil || typ != registry.SZ || s != "Demo" {
+		t.Errorf("DisplayName = %q, type %d, %v; want \"Demo\" REG_SZ", s, typ, err)
+	}
+	for name, want := range map[string]uint64{"EstimatedSize": 12345, "NoModify": 0} {
+		if v, typ, err := k.GetIntegerValue(name); err != nil || typ != registry.DWORD || v != want {
+			t.Errorf("%s = %d, type %d, %v; want %d REG_DWORD", name, v, typ, err, want)
+		}
+	}
+	if v, typ, err := k.GetStringsValue("Extensions"); err != nil || typ != registry.MULTI_SZ || !slices.Equal(v, []string{".demo", ".dmo"}) {
+		t.Errorf("Extensions = %q, type %d, %v; want REG_MULTI_SZ", v, typ, err)
+	}
+
+	err = setValues(registry.CURRENT_USER, path, map[string]any{"Bad": 1.5})
+	if !errors.Is(err, errUnsupportedValue) {
+		t.Errorf("setValues() with a float error = %v, want %v", err, errUnsupportedValue)
+	}
+	if _, _, err := k.GetStringValue("Bad"); err != registry.ErrNotExist {
+		t.Errorf("unsupported value was written: %v", err)
+	}
+}
@@ -1,40 +1,277 @@
 package installer
 
 import (
-	"archive/tar"
 	"bytes"
 	"compress/gzip"
-	"encoding/binary"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
-)
 
-const magicTrailer = "SFXMAGIC"
+	"exe_installer/installer/kernel"
+)
 
 type Options struct {
 	ProductName             string
-	ExeName                 string
-	InstallDir              string
+	ExeName                 string // 主程序相对安装目录的路径，可含子目录（如 bin/app.exe），不允许绝对路径或 ..
+	InstallDir              string // 固定安装目录，可含环境变量，如 %LOCALAPPDATA%\MyApp 或 $HOME/MyApp（未定义的变量保持原样）
 	CreateDesktopShortcut   bool
 	CreateStartMenuShortcut bool
 	Version                 string
+	SourceDir               string // 非空时递归打包整个目录（保留子目录结构），此时 payloadExe 可为空
 	ShortcutName            string // 新增：快捷方式显示名称（为空则使用 ProductName）
-	CompressionLevel        int    // 压缩等级，使用gzip包的常量（如gzip.BestCompression）
+	CompressionLevel        int    // 压缩等级，使用gzip包的常量（-2 ~ 9，如gzip.BestCompression），0 为不压缩
+	Publisher               string // 发布者，显示在“应用和功能”中
+	Language                string // 安装界面语言（如 "zh-CN"、"en-US"），为空则跟随用户系统
+	// PayloadExe 单文件打包时的主程序路径（SourceDir 为空时使用），CreateInstaller 的 payloadExe 参数会覆盖它
+	PayloadExe string
+	// Messages 额外的界面文案：语言代码 -> 消息键 -> 文本。可新增语言或覆盖内置文案，
+	// 消息键见 kernel/i18n.go 中的内置目录。
+	Messages map[string]map[string]string
+	// InstallScope 默认安装范围：kernel.ScopeMachine（全部用户，默认）或 kernel.ScopeUser（仅当前用户），
+	// 用户仍可在安装时选择或通过 /ALLUSERS、/CURRENTUSER 覆盖
+	InstallScope string
+	// FileAssociations 需要登记的文件类型关联（仅 Windows），卸载时会一并删除
+	FileAssociations []kernel.FileAssoc
+	// FileAttributes 安装后设置的文件属性：归档内路径模式（语法同 path.Match）-> kernel.AttrReadOnly / kernel.AttrHidden
+	// （可用逗号组合），如 {"LICENSE.txt": "readonly", "bin/*.dll": "readonly,hidden"}。非 Windows 平台只读即去掉写权限，隐藏无效
+	FileAttributes map[string]string
+	// TemplateFiles 安装后渲染的模板文件（归档内路径模式，语法同 path.Match，须以 .template 结尾），
+	// 如 "config.json.template"、"conf/*.template"。模板使用 text/template，可用 {{.InstallDir}}、{{.ExePath}}、
+	// {{.ProductName}}、{{.Version}}、{{.Publisher}}，结果写入去掉 .template 后缀的文件
+	TemplateFiles []string
+	// KeepInstallDir 卸载时只删除安装器写入的文件，保留安装目录本身与其中的其他文件，
+	// 适用于安装到用户已有目录（如 C:\Tools）的产品
+	KeepInstallDir bool
+	// AllowAnyInstallDir 允许清空与卸载常规安装位置（Program Files、%LOCALAPPDATA%\Programs，
+	// 其他平台为用户主目录）之外的安装目录，如安装到 D:\Apps\<ProductName>。默认拒绝，以防误删用户数据；
+	// 系统目录、卷根等仍始终拒绝。只能在打包时设置
+	AllowAnyInstallDir bool
+	// WriteWorkers 安装时并发写入文件的协程数，0 为默认值 min(4, CPU 数)，1 为顺序写入；低内存模式总是顺序写入
+	WriteWorkers int
+	// PlatformDirs 跨平台安装包：归档内的目录 -> 平台（"goos" 或 "goos/goarch"），如
+	// {"win": "windows", "linux": "linux", "mac": "darwin"}。安装时只安装与当前平台匹配的目录，
+	// 其内容去掉目录前缀后放在安装目录根部；ExeName 相对该目录
+	PlatformDirs map[string]string
+	// RequiredOS / RequiredArch 限定可安装的系统与架构（GOOS / GOARCH 命名，可列出多个），
+	// 如 []string{"windows"}、[]string{"amd64", "arm64"}；不符时安装器拒绝安装并以退出码 11 结束
+	RequiredOS   []string
+	RequiredArch []string
+	// Windows 服务（可选）：ServiceName 非空时，安装完成后通过服务控制管理器登记服务（只登记不启动），
+	// 安装范围强制为全部用户；卸载时停止并删除服务
+	ServiceName        string
+	ServiceDisplayName string // 显示名称，为空时同 ServiceName
+	ServiceExePath     string // 服务程序，相对安装目录；为空时使用 ExeName
+	ServiceStartType   string // kernel.ServiceStartAuto / ServiceStartManual（默认）/ ServiceStartDisabled
+	// 代码签名（可选）：SignToolPath 非空且提供了证书（PFX 文件或证书指纹）时，
+	// 在归档追加完成后对整个 setup 调用 signtool 签名，并校验签名后仍可自解压。
+	SignToolPath       string // signtool.exe 路径
+	SignCertFile       string // PFX 证书文件
+	SignCertPassword   string // PFX 密码
+	SignCertThumbprint string // 证书存储中的证书 SHA1 指纹（与 SignCertFile 二选一）
+	SignTimestampURL   string // RFC 3161 时间戳服务器，如 http://timestamp.digicert.com
+	// Include / Exclude 打包 SourceDir 时的过滤规则（path.Match 语法，匹配相对 SourceDir 的 / 分隔路径）。
+	// 不含 / 的模式匹配任意一级路径名（如 ".git"、"*.pdb"），含 / 的模式匹配完整相对路径或其上级目录。
+	// Include 非空时只打包命中 Include 的文件；Exclude 优先于 Include。
+	Include []string
+	Exclude []string
+	// Shortcuts 需要创建的快捷方式（目标可为安装目录内的程序或 http(s) 网址）；非空时取代
+	// CreateDesktopShortcut / CreateStartMenuShortcut 两个开关，开始菜单快捷方式统一归入 ProductName 文件夹
+	Shortcuts []kernel.ShortcutSpec
+	// StartMenuFolder 开始菜单中的程序文件夹名称（为空则使用 ProductName），其中还会放置卸载快捷方式
+	StartMenuFolder string
+	// StreamingExtract 低内存模式：安装时边解压边写入磁盘，不在内存中保留全部文件（需要多遍历两次归档）
+	StreamingExtract bool
+	// OverwritePolicy 目标文件已存在时的处理：kernel.OverwriteReplace（默认，安装前清空目录）、
+	// kernel.OverwriteFail（报错中止）或 kernel.OverwriteBackup（重命名为 .bak 后写入）
+	OverwritePolicy string
+	// CreateRestorePoint 安装前创建系统还原点“Before installing <ProductName>”（仅 Windows，需管理员权限，
+	// 系统还原被禁用时跳过并给出警告）
+	CreateRestorePoint bool
+	// FinishURL / FinishReadmeFile 交互安装完成后询问是否打开的网址（仅 http / https）与说明文档（相对安装目录）
+	FinishURL        string
+	FinishReadmeFile string
+	// LaunchAfterInstall 安装成功后自动启动主程序（静默安装同样生效），也可用 --launch 指定
+	LaunchAfterInstall bool
+	// PreserveDirs 卸载时始终保留的目录（相对安装目录，如 "data"），用于存放用户设置、存档等
+	PreserveDirs []string
+	// SigningKey 非空时用 Ed25519 私钥对归档签名，签名与公钥写在归档之后；stub 解包前校验，
+	// 不匹配则拒绝安装。密钥可用 GenerateSigningKey / SaveSigningKey / LoadSigningKey 管理。
+	SigningKey ed25519.PrivateKey
+	// LogLevel 安装器控制台日志级别："debug"、"info"（默认）、"warn" 或 "error"；
+	// 用 --log=<文件> 记录的日志文件始终包含全部级别
+	LogLevel string
+	// CleanBeforeInstall 安装前是否清空安装目录，nil 视为 true；设为 false 时只覆盖同名文件（需确认），
+	// 用于向已有程序目录中安装插件等场景。仅在 OverwritePolicy 为默认的覆盖策略时有意义。
+	CleanBeforeInstall *bool
+	// VerifyCmd 安装完成（文件、快捷方式、注册表）后在安装目录中运行的验证命令，如 `"%APP_EXE%" --version`；
+	// 经系统 shell 执行，可用环境变量 INSTALL_DIR、APP_EXE。退出码非 0 时回滚本次安装并以退出码 9 结束
+	VerifyCmd string
+	// PortableMode 便携模式：只把文件解压到目标目录（默认为安装器所在目录下的 ProductName），
+	// 不生成 uninstall.exe、安装清单、快捷方式与注册表项，也不需要管理员权限
+	PortableMode bool
+	// PreserveTimestamps 归档记录源文件的修改时间，安装后恢复（默认关闭，所有文件为安装时间）
+	PreserveTimestamps bool
+	// SyncWrites 安装时每个文件写入后 fsync，并在写完后同步目录，断电后不会留下空的或不完整的文件。
+	// 适合供电不可靠的嵌入式 / 自助终端设备，写入明显变慢，默认关闭
+	SyncWrites bool
+	// IconFile 安装器自身的图标（.ico），替换 stub 的图标资源，资源管理器、任务栏与控制台窗口均显示该图标。
+	// 需要在 Windows 上打包；交叉编译时请在构建 stub 时用 windres / rsrc 编入图标（见 README）
+	IconFile string
+	// EmitUpdateManifest 非空时在生成安装器后把更新清单（见 UpdateManifest）写到该路径，供程序自动更新时轮询；
+	// 需同时设置 Version 与 UpdateURL（安装器发布后的下载地址）。UpdateMinOSVersion 原样写入清单，安装器不检查
+	EmitUpdateManifest string
+	UpdateURL          string
+	UpdateMinOSVersion string
+}
+
+// BuildResult 描述生成的安装器
+type BuildResult struct {
+	OutputPath string
+	Size       int64  // 安装器最终大小（字节）
+	ResultHash string // 安装器最终内容（含归档、签名块与 Authenticode 签名）的 SHA-256，hex 小写
 }
 
-// CreateInstaller 将 payloadExe 打包并附加到 stubExe 生成 setup
+// CreateInstaller 将 payloadExe（或 opts.SourceDir 整个目录）打包并附加到 stubExe 生成 setup，
+// 需要安装器哈希等信息时使用 BuildInstaller
 func CreateInstaller(stubExe, payloadExe, outputSetup string, opts Options) error {
-	payloadData, err := os.ReadFile(payloadExe)
+	_, err := BuildInstaller(stubExe, payloadExe, outputSetup, opts)
+	return err
+}
+
+// BuildInstaller 与 CreateInstaller 相同，并返回生成结果：相当于依次调用 BuildArchive 与 AppendArchive，
+// 在设置了 SignToolPath 时对结果签名，最后计算写出文件的 SHA-256
+func BuildInstaller(stubExe, payloadExe, outputSetup string, opts Options) (BuildResult, error) {
+	if payloadExe != "" {
+		opts.PayloadExe = payloadExe
+	}
+	if err := validateUpdateOptions(opts); err != nil {
+		return BuildResult{}, err
+	}
+	archive, files, err := buildArchive(&opts)
 	if err != nil {
-		return fmt.Errorf("read payload: %w", err)
+		return BuildResult{}, err
+	}
+	// 图标须在追加归档之前写入 stub 的副本
+	if opts.IconFile != "" {
+		patched, cleanup, err := stubWithIcon(stubExe, opts.IconFile)
+		if err != nil {
+			return BuildResult{}, err
+		}
+		defer cleanup()
+		stubExe = patched
+	}
+	if err := AppendArchive(stubExe, outputSetup, archive); err != nil {
+		return BuildResult{}, err
 	}
 
+	if opts.SignToolPath != "" {
+		if err := signSetup(outputSetup, opts); err != nil {
+			return BuildResult{}, fmt.Errorf("sign setup: %w", err)
+		}
+		// 签名追加在文件末尾，确认 stub 仍能越过签名找到归档
+		embedded, err := kernel.ReadEmbeddedArchive(outputSetup)
+		if err != nil {
+			return BuildResult{}, fmt.Errorf("signed setup no longer self-extracts: %w", err)
+		}
+		if unsigned, _ := kernel.SplitSignedArchive(archive); !bytes.Equal(embedded, unsigned) {
+			return BuildResult{}, fmt.Errorf("signed setup no longer self-extracts: archive mismatch")
+		}
+		fmt.Printf("已签名: %s\n", outputSetup)
+	}
+
+	// 哈希取自磁盘上的最终文件，与发布出去的内容一致
+	result, err := hashOutput(outputSetup)
+	if err != nil {
+		return BuildResult{}, fmt.Errorf("hash setup: %w", err)
+	}
+
+	fmt.Printf("生成安装器: %s\n", outputSetup)
+	metaSize := len(files["meta.json"])
+	if opts.SourceDir != "" {
+		fmt.Printf("  内含目录: %s (%d 个条目), meta.json (%d bytes)\n", opts.SourceDir, len(files)-1, metaSize)
+	} else {
+		fmt.Printf("  内含文件: %s, meta.json (%d bytes)\n", opts.ExeName, metaSize)
+	}
+	fmt.Printf("  SHA-256: %s\n", result.ResultHash)
+
+	if opts.EmitUpdateManifest != "" {
+		if err := writeUpdateManifest(opts, result, time.Now()); err != nil {
+			return BuildResult{}, fmt.Errorf("write update manifest: %w", err)
+		}
+		fmt.Printf("更新清单: %s\n", opts.EmitUpdateManifest)
+	}
+	return result, nil
+}
+
+// hashOutput 读取生成的安装器并计算大小与 SHA-256
+func hashOutput(path string) (BuildResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BuildResult{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return BuildResult{}, err
+	}
+	return BuildResult{OutputPath: path, Size: n, ResultHash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// BuildArchive 按 opts 打包 opts.SourceDir 或 opts.PayloadExe 及 meta.json，返回可交给 AppendArchive 的归档。
+// 设置了 SigningKey 时返回值末尾附带签名块。同一归档可追加到多个 stub（如不同品牌的 stub）。
+func BuildArchive(opts Options) ([]byte, error) {
+	archive, _, err := buildArchive(&opts)
+	return archive, err
+}
+
+// buildArchive 补全 opts 的默认值并打包，同时返回归档内的文件（含 meta.json）
+func buildArchive(opts *Options) ([]byte, map[string][]byte, error) {
+	var files map[string][]byte
+	modTimes := map[string]time.Time{}
+	if opts.SourceDir != "" {
+		var err error
+		if files, err = collectSourceDir(opts.SourceDir, opts.Include, opts.Exclude, modTimes); err != nil {
+			return nil, nil, fmt.Errorf("read source dir: %w", err)
+		}
+		if _, ok := files["meta.json"]; ok {
+			return nil, nil, fmt.Errorf("source dir must not contain a top-level meta.json (reserved)")
+		}
+	} else {
+		if opts.PayloadExe == "" {
+			return nil, nil, fmt.Errorf("PayloadExe or SourceDir is required")
+		}
+		payloadData, err := os.ReadFile(opts.PayloadExe)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read payload: %w", err)
+		}
+		if opts.ExeName == "" {
+			opts.ExeName = filepath.Base(opts.PayloadExe)
+		}
+		files = map[string][]byte{opts.ExeName: payloadData}
+		if info, err := os.Stat(opts.PayloadExe); err == nil {
+			modTimes[opts.ExeName] = info.ModTime()
+		}
+	}
+
+	if opts.ExeName == "" && opts.PayloadExe != "" {
+		opts.ExeName = filepath.Base(opts.PayloadExe)
+	}
 	if opts.ExeName == "" {
-		opts.ExeName = filepath.Base(payloadExe)
+		return nil, nil, fmt.Errorf("ExeName is required when packaging SourceDir without payloadExe")
+	}
+	if _, err := kernel.LocalPath(opts.ExeName); err != nil {
+		return nil, nil, fmt.Errorf("invalid ExeName: %w", err)
 	}
 	if opts.ProductName == "" {
 		opts.ProductName = "MyApp"
@@ -54,31 +291,185 @@ func CreateInstaller(stubExe, payloadExe, outputSetup string, opts Options) erro
 		"shortcutName":            opts.ShortcutName,
 		"generatedAt":             time.Now().Format(time.RFC3339),
 	}
+	if opts.Publisher != "" {
+		meta["publisher"] = opts.Publisher
+	}
+	if opts.InstallScope != "" {
+		if opts.InstallScope != kernel.ScopeMachine && opts.InstallScope != kernel.ScopeUser {
+			return nil, nil, fmt.Errorf("invalid InstallScope %q", opts.InstallScope)
+		}
+		meta["installScope"] = opts.InstallScope
+	}
+	if len(opts.FileAssociations) > 0 {
+		meta["fileAssociations"] = opts.FileAssociations
+	}
+	if len(opts.FileAttributes) > 0 {
+		for p, spec := range opts.FileAttributes {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, nil, fmt.Errorf("invalid FileAttributes pattern %q: %w", p, err)
+			}
+			if _, _, err := kernel.ParseFileAttributes(spec); err != nil {
+				return nil, nil, fmt.Errorf("invalid FileAttributes for %q: %w", p, err)
+			}
+		}
+		meta["fileAttributes"] = opts.FileAttributes
+	}
+	if len(opts.TemplateFiles) > 0 {
+		for _, p := range opts.TemplateFiles {
+			if _, err := path.Match(p, ""); err != nil || !strings.HasSuffix(p, kernel.TemplateSuffix) {
+				return nil, nil, fmt.Errorf("invalid TemplateFiles pattern %q: must be a path pattern ending in %s", p, kernel.TemplateSuffix)
+			}
+		}
+		meta["templateFiles"] = opts.TemplateFiles
+	}
+	if opts.KeepInstallDir {
+		meta["keepInstallDir"] = true
+	}
+	if opts.AllowAnyInstallDir {
+		meta["allowAnyInstallDir"] = true
+	}
+	if opts.WriteWorkers > 0 {
+		meta["writeWorkers"] = opts.WriteWorkers
+	}
+	if len(opts.PlatformDirs) > 0 {
+		for dir, spec := range opts.PlatformDirs {
+			if _, err := kernel.LocalPath(dir); err != nil {
+				return nil, nil, fmt.Errorf("invalid PlatformDirs entry: %w", err)
+			}
+			if goos, _, _ := strings.Cut(spec, "/"); goos == "" {
+				return nil, nil, fmt.Errorf("invalid platform %q for PlatformDirs entry %q", spec, dir)
+			}
+		}
+		meta["platformDirs"] = opts.PlatformDirs
+	}
+	if len(opts.RequiredOS) > 0 {
+		meta["requiredOS"] = opts.RequiredOS
+	}
+	if len(opts.RequiredArch) > 0 {
+		meta["requiredArch"] = opts.RequiredArch
+	}
+	if opts.ServiceName != "" {
+		meta["serviceName"] = opts.ServiceName
+		if opts.ServiceDisplayName != "" {
+			meta["serviceDisplayName"] = opts.ServiceDisplayName
+		}
+		if opts.ServiceExePath != "" {
+			if _, err := kernel.LocalPath(opts.ServiceExePath); err != nil {
+				return nil, nil, fmt.Errorf("invalid ServiceExePath: %w", err)
+			}
+			meta["serviceExePath"] = opts.ServiceExePath
+		}
+		switch opts.ServiceStartType {
+		case "":
+		case kernel.ServiceStartAuto, kernel.ServiceStartManual, kernel.ServiceStartDisabled:
+			meta["serviceStartType"] = opts.ServiceStartType
+		default:
+			return nil, nil, fmt.Errorf("invalid ServiceStartType %q", opts.ServiceStartType)
+		}
+	}
+	if opts.Language != "" {
+		meta["language"] = opts.Language
+	}
+	if len(opts.Messages) > 0 {
+		meta["messages"] = opts.Messages
+	}
+	if len(opts.Shortcuts) > 0 {
+		meta["shortcuts"] = opts.Shortcuts
+	}
+	if opts.StartMenuFolder != "" {
+		meta["startMenuFolder"] = opts.StartMenuFolder
+	}
+	if opts.StreamingExtract {
+		meta["streamingExtract"] = true
+	}
+	switch opts.OverwritePolicy {
+	case "", kernel.OverwriteReplace:
+	case kernel.OverwriteFail, kernel.OverwriteBackup:
+		meta["overwritePolicy"] = opts.OverwritePolicy
+	default:
+		return nil, nil, fmt.Errorf("invalid OverwritePolicy %q", opts.OverwritePolicy)
+	}
+	if opts.CreateRestorePoint {
+		meta["createRestorePoint"] = true
+	}
+	if opts.FinishURL != "" {
+		if err := kernel.CheckWebURL(opts.FinishURL); err != nil {
+			return nil, nil, fmt.Errorf("invalid FinishURL: %w", err)
+		}
+		meta["finishURL"] = opts.FinishURL
+	}
+	if opts.FinishReadmeFile != "" {
+		meta["finishReadmeFile"] = opts.FinishReadmeFile
+	}
+	if opts.LaunchAfterInstall {
+		meta["launchAfterInstall"] = true
+	}
+	if len(opts.PreserveDirs) > 0 {
+		meta["preserveDirs"] = opts.PreserveDirs
+	}
+	if opts.PreserveTimestamps {
+		meta["preserveTimestamps"] = true
+	}
+	if opts.PortableMode {
+		meta["portableMode"] = true
+	}
+	if opts.SyncWrites {
+		meta["syncWrites"] = true
+	}
+	if opts.VerifyCmd != "" {
+		meta["verifyCmd"] = opts.VerifyCmd
+	}
+	if opts.CleanBeforeInstall != nil {
+		meta["cleanBeforeInstall"] = *opts.CleanBeforeInstall
+	}
+	if opts.LogLevel != "" {
+		if _, err := kernel.ParseLogLevel(opts.LogLevel); err != nil {
+			return nil, nil, err
+		}
+		meta["logLevel"] = opts.LogLevel
+	}
 
 	metaBytes, _ := json.MarshalIndent(meta, "", "  ")
 
-	files := map[string][]byte{
-		opts.ExeName: payloadData,
-		"meta.json":  metaBytes,
+	files["meta.json"] = metaBytes
+
+	// 压缩等级直接交给 gzip：未设置（0）即 gzip.NoCompression
+	compressionLevel := opts.CompressionLevel
+	if compressionLevel < gzip.HuffmanOnly || compressionLevel > gzip.BestCompression {
+		return nil, nil, fmt.Errorf("invalid CompressionLevel %d", compressionLevel)
 	}
 
-	// 设置默认压缩等级
-	compressionLevel := gzip.NoCompression
-	if opts.CompressionLevel == 9 {
-		compressionLevel = gzip.BestCompression
+	// 只有需要保留时间时才写入源文件的修改时间，否则统一为打包时间
+	if !opts.PreserveTimestamps {
+		modTimes = nil
 	}
-	
-	archive, err := buildTarGz(files, compressionLevel)
+	archive, err := kernel.BuildTarGzWithTimes(files, modTimes, compressionLevel)
 	if err != nil {
-		return fmt.Errorf("build archive: %w", err)
+		return nil, nil, fmt.Errorf("build archive: %w", err)
 	}
 
-	stubData, err := os.ReadFile(stubExe)
+	if opts.SigningKey != nil {
+		if len(opts.SigningKey) != ed25519.PrivateKeySize {
+			return nil, nil, fmt.Errorf("invalid SigningKey length %d", len(opts.SigningKey))
+		}
+		archive = append(archive, kernel.SignatureBlock(archive, opts.SigningKey)...)
+	}
+	return archive, files, nil
+}
+
+// AppendArchive 将 BuildArchive 生成的归档（及其签名块）追加到 stubPath 的副本末尾，写为 outPath
+func AppendArchive(stubPath, outPath string, archive []byte) error {
+	stubData, err := os.ReadFile(stubPath)
 	if err != nil {
 		return fmt.Errorf("read stub: %w", err)
 	}
+	archive, sigBlock := kernel.SplitSignedArchive(archive)
+	return writeSetup(outPath, stubData, archive, sigBlock)
+}
 
-	f, err := os.OpenFile(outputSetup, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+// writeSetup 依次写入 stub、归档、签名块（可为 nil）与尾部，尾部带有此前全部内容的完整性哈希
+func writeSetup(outputSetup string, stubData, archive, sigBlock []byte) error {
+	f, err := os.OpenFile(outputSetup, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o755)
 	if err != nil {
 		return fmt.Errorf("create setup: %w", err)
 	}
@@ -90,59 +481,123 @@ func CreateInstaller(stubExe, payloadExe, outputSetup string, opts Options) erro
 	if _, err := f.Write(archive); err != nil {
 		return err
 	}
-
-	lenBuf := make([]byte, 8)
-	binary.LittleEndian.PutUint64(lenBuf, uint64(len(archive)))
-	if _, err := f.Write(lenBuf); err != nil {
+	if _, err := f.Write(sigBlock); err != nil {
 		return err
 	}
-	if _, err := f.Write([]byte(magicTrailer)); err != nil {
+	flags := kernel.FlagChecksum
+	if sigBlock != nil {
+		flags |= kernel.FlagSigned
+	}
+	sum, err := kernel.IntegrityHash(f, int64(len(stubData)+len(archive)+len(sigBlock)))
+	if err != nil {
+		return fmt.Errorf("hash setup: %w", err)
+	}
+	if _, err := f.Write(kernel.Trailer(len(archive), flags, sum)); err != nil {
 		return err
 	}
-
-	fmt.Printf("生成安装器: %s\n", outputSetup)
-	fmt.Printf("  内含文件: %s, meta.json (%d bytes)\n", opts.ExeName, len(metaBytes))
-	return nil
+	return f.Close()
 }
 
-func buildTarGz(files map[string][]byte, compressionLevel int) ([]byte, error) {
-	var buf bytes.Buffer
-	gzw, err := gzip.NewWriterLevel(&buf, compressionLevel)
+// signSetup 调用 signtool 对生成的 setup 签名。
+// 顺序为"先追加归档、再签名"：Authenticode 签名被追加在文件末尾（通常只有几 KB），
+// 位于 Magic 尾部之后，stub 在末尾窗口内倒序查找 Magic 即可越过签名。
+func signSetup(outputSetup string, opts Options) error {
+	args := []string{"sign", "/fd", "SHA256"}
+	switch {
+	case opts.SignCertFile != "":
+		args = append(args, "/f", opts.SignCertFile)
+		if opts.SignCertPassword != "" {
+			args = append(args, "/p", opts.SignCertPassword)
+		}
+	case opts.SignCertThumbprint != "":
+		args = append(args, "/sha1", opts.SignCertThumbprint)
+	default:
+		return fmt.Errorf("SignCertFile or SignCertThumbprint is required")
+	}
+	if opts.SignTimestampURL != "" {
+		args = append(args, "/tr", opts.SignTimestampURL, "/td", "SHA256")
+	}
+	args = append(args, outputSetup)
+
+	// 输出中不会包含密码（signtool 不回显参数），但出错时也不打印命令行
+	out, err := exec.Command(opts.SignToolPath, args...).CombinedOutput()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("signtool failed: %v: %s", err, strings.TrimSpace(string(out)))
 	}
-	tw := tar.NewWriter(gzw)
+	return nil
+}
 
-	now := time.Now()
-	for name, data := range files {
-		h := &tar.Header{
-			Name:    name,
-			Mode:    0o644,
-			Size:    int64(len(data)),
-			ModTime: now,
+// collectSourceDir 递归读取 root 下的文件，返回归档内路径（以 / 分隔）到内容的映射，修改时间记入 modTimes；
+// 空目录以 "name/" 形式保留，符号链接等非常规文件跳过。
+func collectSourceDir(root string, include, exclude []string, modTimes map[string]time.Time) (map[string][]byte, error) {
+	for _, p := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
 		}
-		// 对于 exe 给予执行权限（在 *nix 上）
-		if filepath.Ext(strings.ToLower(name)) == ".exe" {
-			h.Mode = 0o755
+	}
+	files := map[string][]byte{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-		if err := tw.WriteHeader(h); err != nil {
-			tw.Close()
-			gzw.Close()
-			return nil, err
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
 		}
-		if _, err := tw.Write(data); err != nil {
-			tw.Close()
-			gzw.Close()
-			return nil, err
+		if rel == "." {
+			return nil
 		}
-	}
+		name := filepath.ToSlash(rel)
+		if matchPatterns(exclude, name) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// 空目录同样受 Include 约束
+			if entries, err := os.ReadDir(path); err == nil && len(entries) == 0 && (len(include) == 0 || matchPatterns(include, name)) {
+				files[name+"/"] = nil
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(include) > 0 && !matchPatterns(include, name) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[name] = data
+		if info, err := d.Info(); err == nil {
+			modTimes[name] = info.ModTime()
+		}
+		return nil
+	})
+	return files, err
+}
 
-	if err := tw.Close(); err != nil {
-		gzw.Close()
-		return nil, err
-	}
-	if err := gzw.Close(); err != nil {
-		return nil, err
+// matchPatterns 判断相对路径 name 是否命中任一模式（规则见 Options.Include）
+func matchPatterns(patterns []string, name string) bool {
+	parts := strings.Split(name, "/")
+	for _, p := range patterns {
+		p = strings.Trim(filepath.ToSlash(p), "/")
+		if !strings.Contains(p, "/") {
+			for _, part := range parts {
+				if ok, _ := path.Match(p, part); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for i := range parts {
+			if ok, _ := path.Match(p, strings.Join(parts[:i+1], "/")); ok {
+				return true
+			}
+		}
 	}
-	return buf.Bytes(), nil
+	return false
 }
@@ -0,0 +1,151 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"exe_installer/installer/kernel"
+)
+
+func TestCollectSourceDir(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"app.exe", "app.pdb", "debug.log", ".git/config", ".git/objects/ab",
+		"bin/lib.dll", "bin/lib.pdb", "logs/run.log", "docs/readme.txt", "docs/build/out.txt",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{
+			name: "everything",
+			want: []string{".git/config", ".git/objects/ab", "app.exe", "app.pdb", "bin/lib.dll", "bin/lib.pdb",
+				"debug.log", "docs/build/out.txt", "docs/readme.txt", "empty/", "logs/run.log"},
+		},
+		{
+			name:    "exclude .git and logs",
+			exclude: []string{".git", "*.log"},
+			want:    []string{"app.exe", "app.pdb", "bin/lib.dll", "bin/lib.pdb", "docs/build/out.txt", "docs/readme.txt", "empty/"},
+		},
+		{
+			name:    "exclude a nested path",
+			exclude: []string{"docs/build", "/empty/"},
+			want: []string{".git/config", ".git/objects/ab", "app.exe", "app.pdb", "bin/lib.dll", "bin/lib.pdb",
+				"debug.log", "docs/readme.txt", "logs/run.log"},
+		},
+		{
+			name:    "include only binaries",
+			include: []string{"*.exe", "*.dll"},
+			want:    []string{"app.exe", "bin/lib.dll"},
+		},
+		{
+			name:    "include an empty dir",
+			include: []string{"empty"},
+			want:    []string{"empty/"},
+		},
+		{
+			name:    "exclude wins over include",
+			include: []string{"bin"},
+			exclude: []string{"*.pdb"},
+			want:    []string{"bin/lib.dll"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := collectSourceDir(root, tt.include, tt.exclude, map[string]time.Time{})
+			if err != nil {
+				t.Fatalf("collectSourceDir() error = %v", err)
+			}
+			var got []string
+			for name := range files {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("collectSourceDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := collectSourceDir(root, nil, []string{"[bad"}, map[string]time.Time{}); err == nil {
+		t.Error("collectSourceDir() with an invalid pattern should fail")
+	}
+}
+
+func TestAppendArchiveToMultipleStubs(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	for name, data := range map[string]string{"app.exe": "MZ app", "bin/lib.dll": "lib"} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signingKey := range []ed25519.PrivateKey{nil, key} {
+		name := "unsigned"
+		if signingKey != nil {
+			name = "signed"
+		}
+		t.Run(name, func(t *testing.T) {
+			opts := Options{SourceDir: src, ExeName: "app.exe", ProductName: "Demo", SigningKey: signingKey}
+			archive, err := BuildArchive(opts)
+			if err != nil {
+				t.Fatalf("BuildArchive() error = %v", err)
+			}
+			want, _ := kernel.SplitSignedArchive(archive)
+
+			// 两个大小与内容不同的 stub（如不同品牌）共用同一归档
+			for i, stub := range []string{"MZ brand A stub", "MZ brand B stub with a longer body"} {
+				stubPath := filepath.Join(t.TempDir(), "stub.exe")
+				if err := os.WriteFile(stubPath, []byte(stub), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				out := filepath.Join(t.TempDir(), "setup.exe")
+				if err := AppendArchive(stubPath, out, archive); err != nil {
+					t.Fatalf("AppendArchive() to stub %d error = %v", i, err)
+				}
+				got, err := kernel.ReadEmbeddedArchive(out)
+				if err != nil {
+					t.Fatalf("ReadEmbeddedArchive() of stub %d error = %v", i, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("stub %d: embedded archive differs from the built archive", i)
+				}
+				data, err := os.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.HasPrefix(data, []byte(stub)) {
+					t.Errorf("stub %d: setup does not start with the stub", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package installer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GenerateSigningKey 生成用于 Options.SigningKey 的 Ed25519 密钥对
+func GenerateSigningKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// SaveSigningKey 以 hex 编码的 32 字节种子保存私钥（权限 0600），请勿提交到版本库
+func SaveSigningKey(path string, priv ed25519.PrivateKey) error {
+	return os.WriteFile(path, []byte(hex.EncodeToString(priv.Seed())+"\n"), 0o600)
+}
+
+// LoadSigningKey 读取 SaveSigningKey 保存的私钥
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key file %s", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"exe_installer/installer/kernel"
+)
+
+// cliOptions 命令行参数
+type cliOptions struct {
+	Silent bool // /S 或 --silent：无人值守，不询问、不等待回车
+	Force  bool // --force：静默模式下允许清空已有内容的安装目录
+	// Scope 安装范围：/ALLUSERS 或 --scope=machine 为全部用户，/CURRENTUSER 或 --scope=user 为当前用户
+	Scope    string
+	Elevated bool // --elevated：由提权重启追加，防止重复提权
+	Repair   bool // /REPAIR 或 --repair：按安装清单修复缺失或损坏的文件
+	JSON     bool // --json：结束时输出一行 JSON 结果
+	Launch   bool // /LAUNCH 或 --launch：安装成功后启动主程序并直接退出
+	// Config --config=<path>：运行时配置文件，未指定时使用安装器同目录下的 installer.config.json（若存在）
+	Config string
+	// LogFile --log=<path>：将全部级别的日志（带时间）追加写入该文件
+	LogFile string
+}
+
+var cli cliOptions
+
+// stdin 供所有交互提示共用，避免多个缓冲读取器各自吞掉输入
+var stdin = bufio.NewReader(os.Stdin)
+
+func readLine() string {
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// parseArgs 解析命令行，同时兼容 Windows 风格（/S）与 GNU 风格（--silent）；未知参数忽略
+func parseArgs(args []string) cliOptions {
+	var o cliOptions
+	for _, a := range args {
+		switch strings.ToLower(a) {
+		case "/s", "-s", "--silent":
+			o.Silent = true
+		case "/force", "--force":
+			o.Force = true
+		case "/allusers", "--scope=machine":
+			o.Scope = kernel.ScopeMachine
+		case "/currentuser", "--scope=user":
+			o.Scope = kernel.ScopeUser
+		case "--elevated":
+			o.Elevated = true
+		case "/repair", "--repair":
+			o.Repair = true
+		case "--json":
+			o.JSON = true
+		case "/launch", "--launch":
+			o.Launch = true
+		default:
+			// 路径区分大小写，从原参数截取
+			switch lower := strings.ToLower(a); {
+			case strings.HasPrefix(lower, "--config="):
+				o.Config = a[len("--config="):]
+			case strings.HasPrefix(lower, "--log="):
+				o.LogFile = a[len("--log="):]
+			}
+		}
+	}
+	return o
+}
+
+// confirm 在控制台提问，仅当输入 y/yes 时返回 true
+func confirm(question string) bool {
+	fmt.Print(question)
+	switch strings.ToLower(readLine()) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// confirmDefault 在控制台提问 [Y/n] 或 [y/N]，直接回车返回 def
+func confirmDefault(question string, def bool) bool {
+	fmt.Print(question)
+	switch strings.ToLower(readLine()) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// chooseScope 交互式选择安装范围，直接回车保留默认值
+func chooseScope(def string) string {
+	defChoice := 1
+	if def == kernel.ScopeUser {
+		defChoice = 2
+	}
+	fmt.Print(kernel.T("chooseScope", defChoice))
+	switch readLine() {
+	case "1":
+		return kernel.ScopeMachine
+	case "2":
+		return kernel.ScopeUser
+	}
+	if defChoice == 2 {
+		return kernel.ScopeUser
+	}
+	return kernel.ScopeMachine
+}
+
+// chooseShortcuts 逐个询问是否创建 meta.Shortcuts 中的快捷方式（默认创建），只保留选中的。
+// 全部取消时同时关闭两个旧开关，避免 ShortcutSpecs 退回按旧开关生成。
+func chooseShortcuts() {
+	var keep []kernel.ShortcutSpec
+	for _, s := range meta.Shortcuts {
+		name := s.Name
+		if name == "" {
+			name = meta.DisplayShortcutName()
+		}
+		where := kernel.T("locStartMenu")
+		if s.Location == kernel.ShortcutDesktop {
+			where = kernel.T("locDesktop")
+		}
+		if confirmDefault(kernel.T("chooseShortcut", name, where), true) {
+			keep = append(keep, s)
+		}
+	}
+	meta.Shortcuts = keep
+	if len(keep) == 0 {
+		meta.CreateDesktopShortcut, meta.CreateStartMenuShortcut = false, false
+	}
+}
+
+// waitForFileRelease 在 path 被占用时提示关闭程序并重试，返回 false 表示取消；静默模式不等待
+func waitForFileRelease(path string) bool {
+	for kernel.FileInUse(path) {
+		kernel.Log.Warn(kernel.T("fileInUse", meta.ProductName, path))
+		if cli.Silent {
+			return false
+		}
+		fmt.Print(kernel.T("retryOrCancel"))
+		line, err := stdin.ReadString('\n')
+		if err != nil || strings.EqualFold(strings.TrimSpace(line), "c") {
+			return false
+		}
+	}
+	return true
+}
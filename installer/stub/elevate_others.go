@@ -0,0 +1,7 @@
+//go:build !windows
+
+package main
+
+// 非 Windows 平台不做提权处理
+func isElevated() bool                            { return true }
+func relaunchElevated(args []string) (int, error) { _ = args; return 0, nil }
@@ -0,0 +1,80 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"exe_installer/installer/kernel"
+
+	"golang.org/x/sys/windows"
+)
+
+var procShellExecuteExW = syscall.NewLazyDLL("shell32.dll").NewProc("ShellExecuteExW")
+
+const seeMaskNoCloseProcess = 0x00000040 // SEE_MASK_NOCLOSEPROCESS：返回子进程句柄
+
+// shellExecuteInfo 即 SHELLEXECUTEINFOW
+type shellExecuteInfo struct {
+	cbSize         uint32
+	fMask          uint32
+	hwnd           uintptr
+	lpVerb         *uint16
+	lpFile         *uint16
+	lpParameters   *uint16
+	lpDirectory    *uint16
+	nShow          int32
+	hInstApp       uintptr
+	lpIDList       uintptr
+	lpClass        *uint16
+	hkeyClass      uintptr
+	dwHotKey       uint32
+	hIconOrMonitor uintptr
+	hProcess       windows.Handle
+}
+
+// isElevated 报告当前进程是否以管理员身份运行
+func isElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// relaunchElevated 通过 ShellExecuteEx "runas" 以管理员身份重新启动自身（触发 UAC），
+// 追加 --elevated 以防止循环提权。等待子进程结束并返回其退出码；用户拒绝 UAC 时返回错误。
+func relaunchElevated(args []string) (int, error) {
+	exe, err := kernel.SelfPath()
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, 0, len(args)+1)
+	for _, a := range args {
+		quoted = append(quoted, syscall.EscapeArg(a))
+	}
+	quoted = append(quoted, "--elevated")
+	cwd, _ := os.Getwd()
+
+	info := shellExecuteInfo{fMask: seeMaskNoCloseProcess, nShow: windows.SW_NORMAL}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	info.lpVerb, _ = windows.UTF16PtrFromString("runas")
+	info.lpFile, _ = windows.UTF16PtrFromString(exe)
+	info.lpParameters, _ = windows.UTF16PtrFromString(strings.Join(quoted, " "))
+	info.lpDirectory, _ = windows.UTF16PtrFromString(cwd)
+	if ok, _, err := procShellExecuteExW.Call(uintptr(unsafe.Pointer(&info))); ok == 0 {
+		return 0, err
+	}
+	if info.hProcess == 0 {
+		return 0, fmt.Errorf("ShellExecuteEx returned no process handle")
+	}
+	defer windows.CloseHandle(info.hProcess)
+	if _, err := windows.WaitForSingleObject(info.hProcess, windows.INFINITE); err != nil {
+		return 0, err
+	}
+	var code uint32
+	if err := windows.GetExitCodeProcess(info.hProcess, &code); err != nil {
+		return 0, err
+	}
+	return int(code), nil
+}
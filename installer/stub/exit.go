@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"exe_installer/installer/kernel"
+)
+
+// 退出码，按失败类别区分，供脚本化部署判断（对照表见 README）
+const (
+	exitOK        = 0
+	exitCancelled = 1  // 用户取消，或静默模式下缺少 --force
+	exitExtract   = 3  // 读取或解包内嵌归档失败，或归档中缺少主程序
+	exitWrite     = 4  // 创建目录、清理、写入文件或修复失败
+	exitRegistry  = 5  // 写入注册表或文件关联失败（文件已安装）
+	exitShortcut  = 6  // 创建快捷方式失败（文件已安装）
+	exitElevation = 7  // 无法获得管理员权限
+	exitConfig    = 8  // 运行时配置文件无法读取或无效
+	exitVerify    = 9  // 安装验证命令失败（已回滚）
+	exitService   = 10 // 登记 Windows 服务失败（文件已安装）
+	exitPlatform  = 11 // 当前系统或架构不符合安装包要求
+)
+
+// result 为 --json 输出的结果行
+var result struct {
+	OK         bool   `json:"ok"`
+	Code       int    `json:"code"`
+	Error      string `json:"error,omitempty"`
+	Product    string `json:"product,omitempty"`
+	Version    string `json:"version,omitempty"`
+	InstallDir string `json:"installDir,omitempty"`
+	ExePath    string `json:"exePath,omitempty"`
+}
+
+// onFail 在 fail 退出前执行（os.Exit 不会运行 defer），用于撤销未完成的操作
+var onFail []func()
+
+// fail 打印错误并等待回车（非静默）后以 code 退出
+func fail(code int, msg string) {
+	kernel.Log.Error(msg)
+	for i := len(onFail) - 1; i >= 0; i-- {
+		onFail[i]()
+	}
+	_ = pressAnyKey()
+	exit(code, msg)
+}
+
+// exit 按需输出一行 JSON 结果后以 code 退出
+func exit(code int, msg string) {
+	if cli.JSON {
+		result.OK = code == exitOK
+		result.Code = code
+		result.Error = msg
+		result.Product = meta.ProductName
+		result.Version = meta.Version
+		line, _ := json.Marshal(result)
+		fmt.Println(string(line))
+	}
+	os.Exit(code)
+}
+
+// exitElevated 以管理员身份重新运行自身（见 relaunchElevated），等待其结束并以其退出码退出；
+// 无法启动或用户拒绝 UAC 时以 exitElevation 退出
+func exitElevated(args []string) {
+	code, err := relaunchElevated(args)
+	if err != nil {
+		fail(exitElevation, kernel.T("elevateError", err))
+	}
+	msg := ""
+	if code != exitOK {
+		msg = kernel.T("elevatedChildFailed", code)
+		kernel.Log.Error(msg)
+	}
+	exit(code, msg)
+}
@@ -1,429 +1,568 @@
 package main
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
-	"encoding/binary"
-	"encoding/json"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-)
 
-const (
-	magicTrailer = "SFXMAGIC"
-	trailerSize  = 8 + 8
+	"exe_installer/installer/kernel"
 )
 
-// InstallMeta 与打包时的 meta.json 对应
-type InstallMeta struct {
-	ProductName             string `json:"productName"`
-	ExeName                 string `json:"exeName"`
-	InstallDir              string `json:"installDir"`
-	CreateDesktopShortcut   bool   `json:"createDesktopShortcut"`
-	CreateStartMenuShortcut bool   `json:"createStartMenuShortcut"`
-	Version                 string `json:"version"`
-	GeneratedAt             string `json:"generatedAt"`
-	ShortcutName            string `json:"shortcutName"`
-}
-
 // 默认值（若 meta.json 缺失）
-var meta = InstallMeta{
-	ProductName:             "MyApp",
-	ExeName:                 "app.exe",
-	InstallDir:              "",
-	CreateDesktopShortcut:   true,
-	CreateStartMenuShortcut: true,
-	ShortcutName:            "", // 为空表示使用 ProductName
-}
-
-type inMemoryFile struct {
-	Name string
-	Mode int64
-	Data []byte
-}
+var meta = kernel.DefaultMeta()
 
 func main() {
-	if isUninstallMode() {
+	kernel.SetLanguage(kernel.DetectLanguage())
+	cli = parseArgs(os.Args[1:])
+	if cli.LogFile != "" {
+		// 追加写入：提权重启后的实例沿用同一日志文件
+		f, err := os.OpenFile(cli.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fail(exitWrite, kernel.T("logFileFailed", err))
+		}
+		kernel.Log.SetFile(f)
+	}
+	if isUninstallMode() && !cli.Repair {
 		runUninstall()
 		return
 	}
 
-	fmt.Println("正在安装，请稍候...")
+	kernel.Log.Info(kernel.T("installing"))
 
-	archive, err := extractSelf()
+	archive, archiveSum, err := kernel.ExtractSelf()
 	if err != nil {
-		fmt.Printf("无法提取内置归档: %v\n", err)
-		_ = pressAnyKey()
-		return
+		if errors.Is(err, kernel.ErrSignatureInvalid) {
+			fail(exitExtract, kernel.T("signatureInvalid", err))
+		}
+		if errors.Is(err, kernel.ErrCorrupted) {
+			fail(exitExtract, kernel.T("installerCorrupted"))
+		}
+		fail(exitExtract, kernel.T("extractSelfFailed", err))
+	}
+
+	// 先只解析 meta.json：低内存模式（StreamingExtract）下不把整个归档解到内存，而是边解压边写入
+	// 缺少 meta.json 多半是打包错误，提示后按默认值继续，避免悄悄以错误的产品名安装
+	if err := kernel.LoadArchiveMeta(archive, kernel.DefaultArchiveLimits, &meta); errors.Is(err, kernel.ErrMetaNotFound) {
+		kernel.Log.Warn(kernel.T("metaMissing"))
+	} else if err != nil {
+		fail(exitExtract, kernel.T("unpackFailed", err))
+	}
+	applyConfigFile()
+	if level, err := kernel.ParseLogLevel(meta.LogLevel); err == nil {
+		kernel.Log.SetLevel(level)
+	}
+	kernel.Log.Debug(fmt.Sprintf("archive %d bytes, product %q %q, exe %q, scope %q", len(archive), meta.ProductName, meta.Version, meta.ExeName, meta.InstallScope))
+	// 跨平台安装包只安装当前平台的目录（PlatformDirs）
+	limits := kernel.DefaultArchiveLimits
+	limits.Select = meta.PlatformSelector()
+	streaming := meta.StreamingExtract && !cli.Repair
+	kernel.Log.Info(kernel.T("unpacking"))
+	var files []*kernel.InMemoryFile
+	var fileCount int
+	var totalSize int64
+	if streaming {
+		fileCount, totalSize, err = kernel.ScanArchiveStats(archive, limits)
+	} else {
+		files, err = kernel.UntarGzToMemory(archive, limits)
+		fileCount, totalSize = kernel.ArchiveStats(files)
 	}
-
-	fmt.Println("正在解压归档...")
-	files, err := untarGzToMemory(archive)
 	if err != nil {
-		fmt.Printf("解包归档失败: %v\n", err)
-		_ = pressAnyKey()
-		return
+		fail(exitExtract, kernel.T("unpackFailed", err))
 	}
-	fmt.Printf("解压完成，共 %d 个条目。\n", len(files))
-
-	// 解析 meta.json
-	if m := findFile(files, "meta.json"); m != nil {
-		_ = json.Unmarshal(m.Data, &meta) // 宽松处理
+	if streaming {
+		kernel.Log.Info(kernel.T("streamingMode"))
+	} else {
+		kernel.Log.Info(kernel.T("unpacked", len(files)))
 	}
-	fmt.Printf("产品: %s  版本: %s\n", meta.ProductName, meta.Version)
 
-	installDir, err := decideInstallDir(meta.ProductName, meta.InstallDir)
-	if err != nil {
-		fmt.Printf("创建安装目录失败: %v\n", err)
-		_ = pressAnyKey()
-		return
+	kernel.ApplyLanguage(meta)
+	if required, ok := meta.CheckPlatform(); !ok {
+		fail(exitPlatform, kernel.T("platformMismatch", required, kernel.PlatformString()))
 	}
-	fmt.Printf("目标安装目录: %s\n", installDir)
-
-	// 在写入之前清理旧内容（保留目录本身），避免残留旧版本文件
-	fmt.Println("清理旧版本文件（若存在）...")
-	if err := cleanInstallDir(installDir); err != nil {
-		fmt.Printf("清理已有目录失败: %v\n", err)
-		_ = pressAnyKey()
-		return
+	// ExeName 与安装目录拼接后用于启动、快捷方式与注册表，必须位于安装目录之内
+	if meta.ExeName, err = kernel.LocalPath(meta.ExeName); err != nil {
+		fail(exitExtract, kernel.T("invalidExeName", err))
 	}
-	fmt.Println("目录清理完成，开始写入文件...")
+	kernel.Log.Info(kernel.T("product", meta.ProductName, meta.Version))
+	printDetails(archiveSum, fileCount, totalSize)
 
-	if err := writeFilesWithLog(files, installDir); err != nil {
-		fmt.Printf("写文件失败: %v\n", err)
-		_ = pressAnyKey()
-		return
+	// 从“应用和功能”的修改入口（uninstall.exe --repair）启动时，就地修复其所在目录
+	if cli.Repair && isUninstallMode() {
+		exe, _ := kernel.SelfPath()
+		meta.InstallDir = filepath.Dir(exe)
+		if cli.Scope == "" {
+			cli.Scope = kernel.ScopeUser
+			if kernel.InstalledPerMachine(meta.ProductName) {
+				cli.Scope = kernel.ScopeMachine
+			}
+		}
 	}
-	fmt.Println("文件写入完成。")
 
-	fmt.Printf("已安装到: %s\n", installDir)
-
-	// 确定实际 exe 路径
-	exePath := filepath.Join(installDir, meta.ExeName)
-	if _, err := os.Stat(exePath); err != nil {
-		fmt.Printf("未找到指定主程序 %s，尝试自动查找...\n", meta.ExeName)
-		if detected := detectAnyExe(installDir); detected != "" {
-			fmt.Printf("自动发现可执行文件: %s\n", detected)
-			exePath = detected
-		} else {
-			fmt.Println("未发现任何 .exe，跳过快捷方式创建。")
-			_ = pressAnyKey()
-			return
+	// 便携模式：默认解压到安装器旁边，不写注册表，因此也不需要提权
+	if meta.PortableMode {
+		if meta.InstallDir == "" {
+			exe, _ := kernel.SelfPath()
+			meta.InstallDir = filepath.Join(filepath.Dir(exe), meta.ProductName)
 		}
+		meta.InstallScope = kernel.ScopeUser
+		kernel.Log.Info(kernel.T("portableMode"))
 	}
 
-	if runtime.GOOS == "windows" && (meta.CreateDesktopShortcut || meta.CreateStartMenuShortcut) {
-		fmt.Println("开始创建快捷方式...")
-		if err := createShortcuts(exePath, installDir, meta); err != nil {
-			fmt.Printf("创建快捷方式失败（忽略）：%v\n", err)
-		} else {
-			fmt.Println("快捷方式创建完成。")
+	// 安装范围：命令行 > 交互选择 > meta 默认；全部用户安装需要管理员权限
+	if cli.Scope != "" && !meta.PortableMode {
+		meta.InstallScope = cli.Scope
+	} else if !cli.Silent && !cli.Repair && !meta.PortableMode && runtime.GOOS == "windows" {
+		meta.InstallScope = chooseScope(meta.InstallScope)
+	}
+	// 服务由服务控制管理器统一管理，只能按全部用户安装
+	if meta.HasService() && !meta.PortableMode && runtime.GOOS == "windows" && !meta.PerMachine() {
+		kernel.Log.Info(kernel.T("serviceNeedsMachine", meta.ServiceName))
+		meta.InstallScope = kernel.ScopeMachine
+	}
+	if meta.PerMachine() && !isElevated() {
+		if cli.Elevated {
+			fail(exitElevation, kernel.T("elevationFailed"))
 		}
+		kernel.Log.Info(kernel.T("elevating"))
+		exitElevated(append(os.Args[1:], "--scope="+kernel.ScopeMachine))
 	}
 
-	// 生成卸载程序并写入注册表（仅 Windows 生效）
-	if runtime.GOOS == "windows" {
-		if err := createUninstaller(installDir); err != nil {
-			fmt.Printf("创建卸载程序失败（忽略）：%v\n", err)
-		}
-		if err := writeRegistry(meta, installDir, exePath); err != nil {
-			fmt.Printf("写入注册表失败（忽略）：%v\n", err)
-		} else {
-			fmt.Println("已写入注册表信息。")
-		}
+	installDir, err := kernel.DecideInstallDir(meta.ProductName, meta.InstallDir, meta.PerMachine())
+	if err == nil {
+		// 在任何清理或写入之前确认目录可写，避免做到一半才因权限失败
+		err = kernel.CheckWritable(installDir)
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		fail(exitWrite, kernel.T("dirNotWritable", installDir))
+	}
+	if err != nil {
+		fail(exitWrite, kernel.T("mkInstallDirFailed", err))
 	}
+	result.InstallDir = installDir
+	kernel.Log.Info(kernel.T("installDir", installDir))
+	printDiskSpace(installDir, totalSize)
 
-	fmt.Println("安装完成，祝您使用愉快！")
-	_ = pressAnyKey()
-}
+	if cli.Repair {
+		runRepair(files, installDir)
+		return
+	}
 
-func pressAnyKey() error {
-	fmt.Print("按回车退出...")
-	_, err := fmt.Scanln()
-	return err
-}
+	if !cli.Silent && !meta.PortableMode && runtime.GOOS == "windows" && len(meta.Shortcuts) > 0 {
+		chooseShortcuts()
+	}
 
-// ========== 归档解包到内存 ==========
+	// 升级时先停止已登记的服务，否则服务程序占用文件无法覆盖
+	if meta.HasService() && !meta.PortableMode && runtime.GOOS == "windows" {
+		if err := kernel.StopService(meta.ServiceName); err != nil {
+			kernel.Log.Warn(kernel.T("serviceStopFailed", err))
+		}
+	}
 
-func untarGzToMemory(gzData []byte) ([]*inMemoryFile, error) {
-	gzr, err := gzip.NewReader(bytes.NewReader(gzData))
-	if err != nil {
-		return nil, err
+	// 目标程序正在运行时无法覆盖，提示用户关闭后重试
+	if !waitForFileRelease(filepath.Join(installDir, meta.ExeName)) {
+		fail(exitCancelled, kernel.T("cleanAborted"))
 	}
-	defer gzr.Close()
 
-	tr := tar.NewReader(gzr)
-	var out []*inMemoryFile
-	for {
-		h, err := tr.Next()
-		if errors.Is(err, io.EOF) {
-			break
+	// 目录内已有文件时，清理前必须得到确认（静默模式需 --force）；fail / backup 策略不清理
+	clean := meta.ShouldClean()
+	// 覆盖已有安装（存在安装清单）时列出本次更新的变化，便于变更审查
+	if old, err := kernel.ReadManifest(installDir); err == nil {
+		var entries []kernel.ManifestEntry
+		if streaming {
+			entries, err = kernel.ScanManifestEntries(archive, limits)
+		} else {
+			entries = kernel.BuildManifest(meta, files).Files
 		}
 		if err != nil {
-			return nil, err
-		}
-		switch h.Typeflag {
-		case tar.TypeReg:
-			buf := &bytes.Buffer{}
-			if _, err := io.Copy(buf, tr); err != nil {
-				return nil, err
-			}
-			out = append(out, &inMemoryFile{
-				Name: h.Name,
-				Mode: h.Mode,
-				Data: buf.Bytes(),
-			})
-		case tar.TypeDir:
-			// 目录延迟创建
-			out = append(out, &inMemoryFile{
-				Name: h.Name + "/",
-				Mode: h.Mode,
-				Data: nil,
-			})
-		default:
-			// 忽略其他类型
-		}
-	}
-	return out, nil
-}
-
-func findFile(files []*inMemoryFile, name string) *inMemoryFile {
-	for _, f := range files {
-		if f.Name == name {
-			return f
+			fail(exitExtract, kernel.T("unpackFailed", err))
 		}
+		printUpgradeDiff(old, kernel.DiffManifest(old, entries), clean)
 	}
-	return nil
-}
-
-func writeFiles(files []*inMemoryFile, base string) error {
-	for _, f := range files {
-		if strings.HasSuffix(f.Name, "/") {
-			dir := filepath.Join(base, strings.TrimSuffix(f.Name, "/"))
-			if err := os.MkdirAll(dir, 0o755); err != nil {
-				return err
+	preserve := kernel.PreservedPaths(installDir, meta)
+	if n, _ := kernel.CountFiles(installDir, preserve...); clean && n > 0 {
+		if cli.Silent {
+			if !cli.Force {
+				fail(exitCancelled, kernel.T("cleanNeedsForce", installDir, n))
 			}
-			continue
+		} else if !confirm(kernel.T("confirmClean", installDir, n)) {
+			fail(exitCancelled, kernel.T("cleanAborted"))
 		}
-		dest := filepath.Join(base, f.Name)
-		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
-			return err
+	} else if !clean && meta.Overwrite() == kernel.OverwriteReplace {
+		// 不清空目录直接覆盖：同名文件会被替换，同样需要确认
+		var conflicts []string
+		if streaming {
+			conflicts, err = kernel.ScanConflicts(archive, installDir, limits)
+		} else {
+			conflicts = kernel.ConflictingFiles(files, installDir)
 		}
-		mode := os.FileMode(f.Mode)
-		if mode == 0 {
-			mode = 0o644
+		if err != nil {
+			fail(exitExtract, kernel.T("unpackFailed", err))
 		}
-		// Windows 下执行位不会实际影响 exe，可保留
-		if err := os.WriteFile(dest, f.Data, mode); err != nil {
-			return err
+		if n := len(conflicts); n > 0 {
+			if cli.Silent {
+				if !cli.Force {
+					fail(exitCancelled, kernel.T("overwriteNeedsForce", installDir, n))
+				}
+			} else if !confirm(kernel.T("confirmOverwrite", installDir, n, conflicts[0])) {
+				fail(exitCancelled, kernel.T("cleanAborted"))
+			}
 		}
 	}
-	return nil
-}
 
-func writeFilesWithLog(files []*inMemoryFile, base string) error {
-	for i, f := range files {
-		if strings.HasSuffix(f.Name, "/") {
-			dir := filepath.Join(base, strings.TrimSuffix(f.Name, "/"))
-			if err := os.MkdirAll(dir, 0o755); err != nil {
-				return err
-			}
-			fmt.Printf("[%d/%d] 创建目录: %s\n", i+1, len(files), dir)
-			continue
-		}
-		dest := filepath.Join(base, f.Name)
-		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
-			return err
-		}
-		mode := os.FileMode(f.Mode)
-		if mode == 0 {
-			mode = 0o644
-		}
-		if err := os.WriteFile(dest, f.Data, mode); err != nil {
-			return err
+	// 还原点覆盖清理与写入两步；写入失败时撤销
+	restoreSeq, restoring := int64(0), false
+	if meta.CreateRestorePoint && !meta.PortableMode && runtime.GOOS == "windows" {
+		if !isElevated() {
+			kernel.Log.Warn(kernel.T("restorePointSkipped", kernel.T("restorePointNeedsAdmin")))
+		} else if seq, err := kernel.BeginRestorePoint("Before installing " + meta.ProductName); err != nil {
+			kernel.Log.Warn(kernel.T("restorePointSkipped", err))
+		} else {
+			restoreSeq, restoring = seq, true
+			onFail = append(onFail, func() { _ = kernel.EndRestorePoint(restoreSeq, true) })
+			kernel.Log.Info(kernel.T("restorePointCreated"))
 		}
-		fmt.Printf("[%d/%d] 写入文件: %s (%d bytes)\n", i+1, len(files), dest, len(f.Data))
 	}
-	return nil
-}
 
-// ========== 自解压基础 ==========
+	// 在写入之前清理旧内容（保留目录本身），避免残留旧版本文件
+	if clean {
+		kernel.Log.Info(kernel.T("cleaning"))
+		if err := kernel.CleanInstallDir(installDir, meta, preserve...); err != nil {
+			fail(exitWrite, kernel.T("cleanFailed", err))
+		}
+		kernel.Log.Info(kernel.T("cleaned"))
+	}
 
-func extractSelf() ([]byte, error) {
-	self, err := os.Executable()
-	if err != nil {
-		return nil, err
+	progress := kernel.NewProgress()
+	progress.Subscribe(printProgress)
+	writeOpts := meta.WriteOptions(progress)
+	var manifest kernel.Manifest
+	if streaming {
+		entries, err := kernel.StreamToDir(archive, installDir, limits, writeOpts)
+		if err != nil {
+			fail(exitWrite, kernel.T("writeFailed", err))
+		}
+		manifest = kernel.ManifestFor(meta, entries)
+	} else {
+		if err := kernel.WriteFiles(files, installDir, writeOpts); err != nil {
+			fail(exitWrite, kernel.T("writeFailed", err))
+		}
+		manifest = kernel.BuildManifest(meta, files)
 	}
-	f, err := os.Open(self)
-	if err != nil {
-		return nil, err
+	kernel.Log.Info(kernel.T("written"))
+	if restoring {
+		_ = kernel.EndRestorePoint(restoreSeq, false)
+		onFail = nil
 	}
-	defer f.Close()
 
-	info, err := f.Stat()
-	if err != nil {
-		return nil, err
-	}
+	kernel.Log.Info(kernel.T("installedTo", installDir))
 
-	// 1. 定义搜索范围：例如搜索末尾的 64KB
-	// 如果文件被追加了签名，通常只有几 KB，64KB 足够覆盖
-	const searchLimit = 64 * 1024
-	fileSize := info.Size()
-	if fileSize < trailerSize {
-		return nil, fmt.Errorf("file too small")
+	// 确定实际 exe 路径
+	exePath := meta.ExePath(installDir)
+	if _, err := os.Stat(exePath); err != nil {
+		kernel.Log.Warn(kernel.T("exeNotFound", meta.ExeName))
+		if detected := kernel.DetectAnyExe(installDir); detected != "" {
+			kernel.Log.Info(kernel.T("exeDetected", detected))
+			exePath = detected
+		} else {
+			// 安装包本身缺少主程序，属于安装包问题而不是写入失败
+			fail(exitExtract, kernel.T("noExe", meta.ExeName))
+		}
 	}
 
-	readSize := int64(searchLimit)
-	if readSize > fileSize {
-		readSize = fileSize
+	if n, err := kernel.RenderTemplates(installDir, exePath, meta, &manifest); err != nil {
+		fail(exitWrite, kernel.T("templateFailed", err))
+	} else if n > 0 {
+		kernel.Log.Info(kernel.T("templatesRendered", n))
 	}
-
-	// 2. 读取末尾数据块
-	startOffset := fileSize - readSize
-	if _, err := f.Seek(startOffset, io.SeekStart); err != nil {
-		return nil, err
+	if _, err := kernel.ApplyFileAttributes(installDir, meta, manifest); err != nil {
+		kernel.Log.Warn(kernel.T("fileAttrsFailed", err))
 	}
 
-	buf := make([]byte, readSize)
-	if _, err := io.ReadFull(f, buf); err != nil {
-		return nil, err
+	// 便携模式只解压文件，不做系统集成
+	code, warning := exitOK, ""
+	if !meta.PortableMode {
+		code, warning = integrate(installDir, exePath, &manifest, progress)
 	}
 
-	// 3. 在缓冲区中倒序查找 Magic 字符串
-	magicBytes := []byte(magicTrailer)
-	idx := bytes.LastIndex(buf, magicBytes)
-	if idx == -1 {
-		return nil, fmt.Errorf("magic mismatch (signature not found in last %d bytes)", readSize)
+	// 验证命令失败视为安装失败：撤销本次安装的文件与系统集成
+	if meta.VerifyCmd != "" {
+		kernel.Log.Info(kernel.T("verifying"))
+		out, err := kernel.RunVerifyCmd(meta.VerifyCmd, installDir, exePath)
+		level := kernel.Log.Debug
+		if err != nil {
+			level = kernel.Log.Info
+		}
+		for _, line := range strings.Split(strings.TrimRight(out, "\r\n"), "\n") {
+			if line != "" {
+				level("  " + strings.TrimRight(line, "\r"))
+			}
+		}
+		if err != nil {
+			rollback(installDir, manifest)
+			fail(exitVerify, kernel.T("verifyFailed", err))
+		}
+		kernel.Log.Info(kernel.T("verified"))
 	}
 
-	// 4. 校验位置是否有足够的空间存放长度信息 (8 bytes)
-	// Magic 在 buf[idx] 开始，长度信息应该在 buf[idx-8]
-	if idx < 8 {
-		// 这种情况极少见（Magic 刚好被切断在读取边界），但在 64KB 窗口下几乎不可能发生
-		// 除非文件本身就极小且结构损坏
-		return nil, fmt.Errorf("magic found but header truncated")
+	kernel.Log.Info(kernel.T("installDone"))
+	result.ExePath = exePath
+	if !cli.Silent {
+		offerFinishActions(installDir)
 	}
-
-	// 5. 解析长度
-	lenStart := idx - 8
-	archiveLen := binary.LittleEndian.Uint64(buf[lenStart : lenStart+8])
-
-	// 6. 计算归档在文件中的绝对起始位置
-	// buf[lenStart] 在文件中的位置是 startOffset + lenStart
-	// 归档结束位置 = startOffset + lenStart
-	// 归档开始位置 = 归档结束位置 - archiveLen
-	archiveEndOffset := startOffset + int64(lenStart)
-	archiveStartOffset := archiveEndOffset - int64(archiveLen)
-
-	if archiveStartOffset < 0 {
-		return nil, fmt.Errorf("invalid archive start offset")
+	// 自动启动时不再等待回车：程序以独立进程运行，安装器立即退出
+	if cli.Launch || meta.LaunchAfterInstall {
+		if err := kernel.LaunchDetached(exePath); err != nil {
+			kernel.Log.Warn(kernel.T("launchFailed", err))
+		} else {
+			kernel.Log.Info(kernel.T("launched", exePath))
+			exit(code, warning)
+		}
 	}
+	_ = pressAnyKey()
+	exit(code, warning)
+}
 
-	// 7. 读取归档数据
-	if _, err := f.Seek(archiveStartOffset, io.SeekStart); err != nil {
-		return nil, err
+// integrate 生成卸载程序、快捷方式、安装清单与注册表项。这些步骤失败不中止安装，
+// 返回相应的退出码与警告
+func integrate(installDir, exePath string, manifest *kernel.Manifest, progress *kernel.Progress) (code int, warning string) {
+	windows := runtime.GOOS == "windows"
+	shortcuts := windows && len(kernel.ShortcutSpecs(meta, installDir, exePath)) > 0
+	steps := 1 // 安装清单
+	for _, on := range []bool{windows, shortcuts, windows, windows && len(meta.FileAssociations) > 0, windows && meta.HasService()} {
+		if on {
+			steps++
+		}
 	}
+	progress.StartPhase(kernel.PhaseIntegrate, 0, steps)
 
-	archiveBuf := make([]byte, archiveLen)
-	if _, err := io.ReadFull(f, archiveBuf); err != nil {
-		return nil, err
+	// 卸载程序须先于快捷方式生成，开始菜单中的卸载快捷方式才能指向它（仅 Windows 生效）
+	if windows {
+		if err := createUninstaller(installDir); err != nil {
+			kernel.Log.Warn(kernel.T("uninstallerFailed", err))
+		}
+		progress.AddItem(kernel.StepUninstaller, 0, false)
 	}
-
-	return archiveBuf, nil
+	if shortcuts {
+		kernel.Log.Info(kernel.T("creatingShortcuts"))
+		created, err := kernel.CreateShortcuts(meta, installDir, exePath)
+		manifest.Shortcuts, manifest.StartMenuFolder = created.Links, created.StartMenuFolder
+		if err != nil {
+			warning = kernel.T("shortcutsFailed", err)
+			code = exitShortcut
+			kernel.Log.Warn(warning)
+		} else {
+			kernel.Log.Info(kernel.T("shortcutsCreated"))
+		}
+		progress.AddItem(kernel.StepShortcuts, 0, false)
+	}
+
+	// 清单记录安装的文件与快捷方式，供修复与卸载使用
+	if err := kernel.WriteManifest(installDir, *manifest); err != nil {
+		kernel.Log.Warn(kernel.T("manifestFailed", err))
+	}
+	progress.AddItem(kernel.StepManifest, 0, false)
+
+	// 写入注册表（仅 Windows 生效）
+	if windows {
+		if err := kernel.WriteRegistry(meta, installDir, exePath, manifest.InstalledSize(installDir)); err != nil {
+			code = exitRegistry
+			if isElevated() {
+				// 已提升仍写入失败不是权限问题，且缺少卸载键将无法从“设置 > 应用”卸载，不能当作可忽略
+				warning = kernel.T("registryFailedElevated", err, filepath.Join(installDir, "uninstall.exe"))
+				kernel.Log.Error(warning)
+			} else {
+				warning = kernel.T("registryFailed", err)
+				kernel.Log.Warn(warning)
+			}
+		} else if err := kernel.VerifyRegistry(meta, installDir); err != nil {
+			warning = kernel.T("registryVerifyFailed", err, filepath.Join(installDir, "uninstall.exe"))
+			code = exitRegistry
+			kernel.Log.Error(warning)
+		} else {
+			kernel.Log.Info(kernel.T("registryWritten"))
+		}
+		progress.AddItem(kernel.StepRegistry, 0, false)
+		if len(meta.FileAssociations) > 0 {
+			if err := kernel.RegisterFileAssociations(meta.FileAssociations, installDir, exePath); err != nil {
+				warning = kernel.T("fileAssocFailed", err)
+				code = exitRegistry
+				kernel.Log.Warn(warning)
+			} else {
+				kernel.Log.Info(kernel.T("fileAssocRegistered", len(meta.FileAssociations)))
+			}
+			progress.AddItem(kernel.StepFileAssociations, 0, false)
+		}
+		if meta.HasService() {
+			if err := kernel.InstallService(meta, installDir, exePath); err != nil {
+				warning = kernel.T("serviceFailed", meta.ServiceName, err)
+				code = exitService
+				kernel.Log.Error(warning)
+			} else {
+				kernel.Log.Info(kernel.T("serviceInstalled", meta.ServiceName))
+			}
+			progress.AddItem(kernel.StepService, 0, false)
+		}
+	}
+	return code, warning
 }
 
-func decideInstallDir(productName, forced string) (string, error) {
-	if forced != "" {
-		return forced, os.MkdirAll(forced, 0o755)
+// printUpgradeDiff 输出升级摘要与逐个文件的变化（+ 新增，~ 替换，- 删除）。
+// 不清空目录时旧版本独有的文件不会被删除，单独说明。
+func printUpgradeDiff(old kernel.Manifest, d kernel.ManifestDiff, clean bool) {
+	removed := 0
+	if clean {
+		removed = len(d.Removed)
+	}
+	kernel.Log.Info(kernel.T("upgradeDiff", old.Version, len(d.Added), len(d.Replaced), removed))
+	for _, p := range d.Added {
+		kernel.Log.Info("  + " + p)
 	}
-	if runtime.GOOS == "windows" {
-		if pf := os.Getenv("ProgramFiles"); pf != "" {
-			path := filepath.Join(pf, productName)
-			return path, os.MkdirAll(path, 0o755)
+	for _, p := range d.Replaced {
+		kernel.Log.Info("  ~ " + p)
+	}
+	if !clean && len(d.Removed) > 0 {
+		kernel.Log.Info(kernel.T("upgradeObsoleteKept", len(d.Removed)))
+	}
+	for _, p := range d.Removed {
+		if clean {
+			kernel.Log.Info("  - " + p)
+		} else {
+			kernel.Log.Info("    " + p)
 		}
 	}
-	cwd, _ := os.Getwd()
-	path := filepath.Join(cwd, productName)
-	return path, os.MkdirAll(path, 0o755)
 }
 
-// detectAnyExe: 若指定 exeName 不存在，兜底寻找一个 .exe
-func detectAnyExe(root string) string {
-	entries, err := os.ReadDir(root)
-	if err != nil {
-		return ""
-	}
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
+// rollback 撤销本次安装：删除写入的文件（安装前已有的其他文件保留）、卸载程序、快捷方式、
+// 文件关联与注册表项。被清理掉的旧版本无法恢复。
+func rollback(installDir string, m kernel.Manifest) {
+	kernel.Log.Warn(kernel.T("rollingBack"))
+	if !meta.PortableMode && runtime.GOOS == "windows" {
+		_ = kernel.RemoveService(meta.ServiceName)
+		_ = kernel.UnregisterFileAssociations(meta.FileAssociations)
+		_ = kernel.DeleteRegistry(meta.ProductName, meta.PerMachine())
+		for _, link := range m.Shortcuts {
+			_ = os.Remove(link)
 		}
-		name := e.Name()
-		if strings.HasSuffix(strings.ToLower(name), ".exe") {
-			return filepath.Join(root, name)
+		if m.StartMenuFolder != "" {
+			_ = os.Remove(m.StartMenuFolder) // 只在已空时删除
 		}
+		_ = os.Remove(filepath.Join(installDir, "uninstall.exe"))
 	}
-	// 进一步递归一层（可选）
-	for _, e := range entries {
-		if e.IsDir() {
-			sub := filepath.Join(root, e.Name())
-			files, _ := os.ReadDir(sub)
-			for _, se := range files {
-				if !se.IsDir() && strings.HasSuffix(strings.ToLower(se.Name()), ".exe") {
-					return filepath.Join(sub, se.Name())
-				}
-			}
-		}
+	if err := kernel.RemoveInstalledFiles(installDir, m, true); err != nil {
+		kernel.Log.Warn(kernel.T("removeFilesFailed", err))
 	}
-	return ""
 }
 
-// ========== 目录清理（安全） ==========
+// applyConfigFile 将运行时配置文件合并到 meta 之上：--config 指定的文件必须存在，
+// 默认的 installer.config.json 不存在时忽略
+func applyConfigFile() {
+	path, explicit := cli.Config, cli.Config != ""
+	if !explicit {
+		exe, err := kernel.SelfPath()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(filepath.Dir(exe), kernel.ConfigFileName)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return
+	}
+	if err == nil {
+		err = kernel.ApplyConfig(&meta, data)
+	}
+	if err != nil {
+		fail(exitConfig, kernel.T("configFailed", path, err))
+	}
+	kernel.Log.Info(kernel.T("configApplied", path))
+}
 
-func cleanInstallDir(dir string) error {
-	// 若不存在则直接创建由调用者继续
-	info, err := os.Stat(dir)
-	if os.IsNotExist(err) {
-		return nil
+// runRepair 按安装清单校验 installDir，仅重写缺失或损坏的文件
+func runRepair(files []*kernel.InMemoryFile, installDir string) {
+	m, err := kernel.ReadManifest(installDir)
+	if err != nil {
+		fail(exitWrite, kernel.T("repairFailed", err))
 	}
+	kernel.Log.Info(kernel.T("repairing", len(m.Files)))
+	progress := kernel.NewProgress()
+	progress.Subscribe(printProgress)
+	n, err := kernel.RepairFiles(files, installDir, m, progress)
 	if err != nil {
-		return err
+		fail(exitWrite, kernel.T("repairFailed", err))
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("目标路径存在但不是目录: %s", dir)
+	// 重写的文件失去了原有的只读 / 隐藏属性
+	if _, err := kernel.ApplyFileAttributes(installDir, meta, m); err != nil {
+		kernel.Log.Warn(kernel.T("fileAttrsFailed", err))
 	}
-	// 安全保护：禁止删除过于顶层或敏感目录
-	lower := strings.ToLower(filepath.Clean(dir))
-	if lower == "c:/" || lower == "c:\\" || len(lower) <= 3 { // 例如 c:\ 或 d:\
-		return fmt.Errorf("拒绝清理系统根目录: %s", dir)
+	kernel.Log.Info(kernel.T("repairDone", n))
+	_ = pressAnyKey()
+	exit(exitOK, "")
+}
+
+// offerFinishActions 询问是否打开 meta 中配置的官网与说明文档
+func offerFinishActions(installDir string) {
+	if meta.FinishReadmeFile != "" {
+		if rel, err := kernel.LocalPath(meta.FinishReadmeFile); err == nil {
+			readme := filepath.Join(installDir, filepath.FromSlash(rel))
+			if _, err := os.Stat(readme); err == nil && confirm(kernel.T("openReadme", filepath.Base(readme))) {
+				if err := kernel.OpenWithDefault(readme); err != nil {
+					kernel.Log.Warn(kernel.T("openFailed", err))
+				}
+			}
+		}
 	}
-	if pf := os.Getenv("ProgramFiles"); pf != "" {
-		lp := strings.ToLower(filepath.Clean(pf))
-		if lower == lp { // 不能直接是 Program Files 根
-			return fmt.Errorf("拒绝清理 ProgramFiles 根目录: %s", dir)
+	// 网址会交给系统默认程序打开，只接受 http / https，防止打开本地程序
+	if meta.FinishURL != "" && kernel.CheckWebURL(meta.FinishURL) == nil && confirm(kernel.T("openURL", meta.FinishURL)) {
+		if err := kernel.OpenWithDefault(meta.FinishURL); err != nil {
+			kernel.Log.Warn(kernel.T("openFailed", err))
 		}
 	}
-	// 额外保护：必须包含产品名（防止 meta 空 productName）
-	if meta.ProductName == "" || !strings.Contains(lower, strings.ToLower(meta.ProductName)) {
-		// 仅警告，不中断——但为了安全这里直接拒绝
-		return fmt.Errorf("目录不包含产品名，取消清理: %s", dir)
+}
+
+// printDetails 安装前展示将要安装的内容，便于用户核对；sum 为读取归档时已算出的 SHA-256
+func printDetails(sum [32]byte, count int, size int64) {
+	publisher := meta.Publisher
+	if publisher == "" {
+		publisher = "-"
 	}
-	entries, err := os.ReadDir(dir)
+	kernel.Log.Info(kernel.T("details", count, kernel.FormatSize(size), publisher, hex.EncodeToString(sum[:])))
+}
+
+// printDiskSpace 显示所需与可用磁盘空间；可用空间不足时只警告（覆盖安装会先清理旧文件腾出空间）
+func printDiskSpace(installDir string, need int64) {
+	free, err := kernel.FreeSpace(installDir)
 	if err != nil {
-		return err
+		return
 	}
-	for _, e := range entries {
-		name := e.Name()
-		// 不删除正在运行的卸载程序 / 安装程序（理论上不在此目录）
-		full := filepath.Join(dir, name)
-		if err := os.RemoveAll(full); err != nil {
-			return fmt.Errorf("删除 %s 失败: %w", name, err)
-		}
+	kernel.Log.Info(kernel.T("diskSpace", kernel.FormatSize(need), kernel.FormatSize(free)))
+	if free < need {
+		kernel.Log.Warn(kernel.T("diskSpaceLow"))
 	}
-	return nil
+}
+
+// printProgress 将写入进度逐条打印到控制台
+func printProgress(ev kernel.ProgressEvent) {
+	// 系统集成的各步骤自行输出结果
+	if ev.Item == "" || ev.Phase != kernel.PhaseWrite {
+		return
+	}
+	if ev.IsDir {
+		kernel.Log.Info(kernel.T("mkdirLog", ev.Done, ev.Count, ev.Item))
+	} else {
+		kernel.Log.Info(kernel.T("writeLog", ev.Done, ev.Count, ev.Item, ev.Size))
+	}
+}
+
+func pressAnyKey() error {
+	if cli.Silent {
+		return nil
+	}
+	fmt.Print(kernel.T("pressEnter"))
+	_, err := stdin.ReadString('\n')
+	return err
 }
@@ -3,17 +3,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 
-	"golang.org/x/sys/windows/registry"
+	"exe_installer/installer/kernel"
+
+	"golang.org/x/sys/windows"
 )
 
 // 判断当前是否为卸载模式：可执行文件名包含 "uninstall"。
 func isUninstallMode() bool {
-	exe, err := os.Executable()
+	exe, err := kernel.SelfPath()
 	if err != nil {
 		return false
 	}
@@ -25,7 +29,7 @@ func isUninstallMode() bool {
 // 由于 stub 已内置安装逻辑，我们生成一个精简的卸载入口可执行：这里采取写一个小的批次逻辑：
 // 简化实现：直接复制当前 exe 为 uninstall.exe 并在注册表中区分。实际更优方式是构建一个单独 Uninstaller 源码。
 func createUninstaller(installDir string) error {
-	exe, err := os.Executable()
+	exe, err := kernel.SelfPath()
 	if err != nil {
 		return err
 	}
@@ -42,90 +46,127 @@ func createUninstaller(installDir string) error {
 
 // runUninstall 卸载流程：读取注册表信息推断安装目录（或当前目录），删除快捷方式、注册表再删除目录。
 func runUninstall() {
-	fmt.Println("正在卸载...")
+	kernel.Log.Info(kernel.T("uninstalling"))
 	// 这里简单：通过可执行所在目录上一级推断安装根目录。
-	exe, _ := os.Executable()
+	exe, _ := kernel.SelfPath()
 	installDir := filepath.Dir(exe)
 
-	// 注册表删除
-	// 我们需要 productName：尝试从目录名推断（末级目录名）
-	productName := filepath.Base(installDir)
-	baseKey := `Software\\` + productName
-	uninstallKey := `Software\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\` + productName
-	_ = registry.DeleteKey(registry.CURRENT_USER, uninstallKey)
-	_ = registry.DeleteKey(registry.CURRENT_USER, baseKey)
-
-	// 删除快捷方式（支持 ShortcutName）
-	shortcutName := productName
-	// 尝试读取基础键 ShortcutName
-	if k, err := registry.OpenKey(registry.CURRENT_USER, baseKey, registry.QUERY_VALUE); err == nil {
-		if v, _, err2 := k.GetStringValue("ShortcutName"); err2 == nil && v != "" {
-			shortcutName = v
-		}
-		k.Close()
-	}
-	desktopLnk := filepath.Join(userDesktopDir(), shortcutName+".lnk")
-	startMenuDirPath := filepath.Join(startMenuProgramsDir(), shortcutName)
-	startMenuLnk := filepath.Join(startMenuDirPath, shortcutName+".lnk")
-	_ = os.Remove(desktopLnk)
-	_ = os.Remove(startMenuLnk)
-	_ = os.RemoveAll(startMenuDirPath)
-
-	// 删除安装目录（延迟删除：因为自身仍在目录内，简单方案：提示手动删除或使用 cmd /c start 新进程延迟）
-	// 为简单起见：尝试直接删除除自身外的文件。
-	entries, _ := os.ReadDir(installDir)
-	for _, e := range entries {
-		p := filepath.Join(installDir, e.Name())
-		if strings.EqualFold(p, exe) { // 保留自身稍后批处理删除
-			continue
+	// 产品名（注册表键名）取自安装清单，旧版本安装没有清单时按目录名推断
+	m, manifestErr := kernel.ReadManifest(installDir)
+	productName := m.ProductName
+	if productName == "" {
+		productName = filepath.Base(installDir)
+	}
+	perMachine := kernel.InstalledPerMachine(productName)
+	if perMachine && !isElevated() {
+		if cli.Elevated {
+			fail(exitElevation, kernel.T("elevationFailed"))
 		}
-		_ = os.RemoveAll(p)
+		kernel.Log.Info(kernel.T("elevating"))
+		exitElevated(os.Args[1:])
+	}
+	// 有安装清单时可保留用户数据：只删除安装器写入的文件，安装后新建的文件与 PreserveDirs 中的目录保留。
+	// 清单要求保留安装目录时无需询问
+	removeDir := !m.KeepInstallDir
+	keepData := manifestErr == nil && (!removeDir || cli.Silent || confirmDefault(kernel.T("keepUserData"), true))
+	// 逐个文件显示进度；Ctrl+C 停止删除后续文件，注册表中的卸载入口保留，可再次卸载
+	progress := kernel.NewProgress()
+	progress.Subscribe(printRemoveProgress)
+	cancel := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		close(cancel)
+	}()
+	if !cli.Silent {
+		kernel.Log.Info(kernel.T("uninstallCancelHint"))
 	}
-	if err := scheduleSelfDelete(exe, installDir); err != nil {
-		fmt.Printf("自删除计划失败（手动删除目录）：%v\n", err)
+	// 自身正在运行，由 scheduleSelfDelete 移走后删除
+	err := kernel.Uninstall(installDir, kernel.UninstallOptions{
+		ProductName:      productName,
+		KeepUserData:     keepData,
+		RemoveInstallDir: &removeDir,
+		Skip:             []string{exe},
+		Progress:         progress,
+		Cancel:           cancel,
+	})
+	signal.Stop(interrupt)
+	if errors.Is(err, kernel.ErrCancelled) {
+		kernel.Log.Warn(kernel.T("uninstallCancelled", productName))
+		return
+	}
+	if err != nil {
+		kernel.Log.Warn(kernel.T("uninstallIncomplete", err))
+	}
+	dirToRemove := installDir
+	if !removeDir {
+		dirToRemove = ""
+	}
+	// 只在 Uninstall 成功且有安装清单时整个删除目录：Uninstall 已做过防误删检查，
+	// 没有清单（旧版本安装）或被拒绝时只删除变空的目录
+	purge := err == nil && manifestErr == nil && !keepData && len(m.PreserveDirs) == 0
+	if err := scheduleSelfDelete(exe, dirToRemove, purge); err != nil {
+		kernel.Log.Warn(kernel.T("selfDeleteFailed", err))
 	} else {
-		fmt.Println("已计划删除卸载程序与安装目录...")
+		kernel.Log.Info(kernel.T("selfDeleteScheduled"))
 	}
-	fmt.Println("卸载完成。")
+	kernel.Log.Info(kernel.T("uninstallDone"))
 }
 
-// userDesktopDir 返回当前用户桌面目录（简单拼接，不做特殊 Shell 查询）。
-func userDesktopDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil || home == "" {
-		return ""
+func printRemoveProgress(ev kernel.ProgressEvent) {
+	if ev.Item != "" {
+		kernel.Log.Info(kernel.T("removeLog", ev.Done, ev.Count, ev.Item))
 	}
-	return filepath.Join(home, "Desktop")
 }
 
-// startMenuProgramsDir 返回开始菜单 Programs 目录。
-func startMenuProgramsDir() string {
-	appData := os.Getenv("AppData")
-	if appData == "" {
-		return ""
+// scheduleSelfDelete 移走正在运行的卸载程序后删除安装目录。Windows 允许在同一卷内重命名正在运行的 exe，
+// 因此先把它移到 %TEMP%（不同卷时移到安装目录的上级目录），安装目录随即可以删除；
+// 移走的 exe 再登记为重启后删除（需要管理员权限，否则留给系统清理临时文件）。
+// 无法移走时退回为 exe 与（变空的）安装目录在重启后删除。purge 为 false 时只在目录已空时删除目录，以免删掉保留的用户数据；
+// installDir 为空表示保留安装目录，只删除 exe。
+func scheduleSelfDelete(exePath, installDir string, purge bool) error {
+	name := fmt.Sprintf("_uninst_%d.exe", os.Getpid())
+	moved := ""
+	for _, dir := range []string{os.TempDir(), filepath.Dir(filepath.Dir(exePath))} {
+		dst := filepath.Join(dir, name)
+		if moveFile(exePath, dst, windows.MOVEFILE_REPLACE_EXISTING) == nil {
+			moved = dst
+			break
+		}
 	}
-	return filepath.Join(appData, "Microsoft", "Windows", "Start Menu", "Programs")
-}
+	if moved == "" {
+		if err := moveFile(exePath, "", windows.MOVEFILE_DELAY_UNTIL_REBOOT); err != nil || installDir == "" {
+			return err
+		}
+		// 重启时按登记顺序处理：exe 删除后目录为空才能删除，保留了用户数据时目录删除失败，不影响其他操作
+		return moveFile(installDir, "", windows.MOVEFILE_DELAY_UNTIL_REBOOT)
+	}
+	_ = moveFile(moved, "", windows.MOVEFILE_DELAY_UNTIL_REBOOT)
 
-// scheduleSelfDelete: 使用临时批处理在当前进程退出后循环尝试删除 exe 与安装目录，最后删除自身批处理。
-func scheduleSelfDelete(exePath, installDir string) error {
-	tempBat := filepath.Join(os.TempDir(), fmt.Sprintf("_uninst_del_%d.bat", os.Getpid()))
-	// 构造批处理：等待1-2秒 -> 删除 exe -> 若仍存在则重试 -> 删除目录 -> 删除批处理
-	script := fmt.Sprintf(`@echo off\r\n`+
-		`set EXE="%s"\r\n`+
-		`set DIR="%s"\r\n`+
-		`:again\r\n`+
-		`ping -n 2 127.0.0.1 >nul\r\n`+
-		`del /f /q %s >nul 2>&1\r\n`+
-		`if exist %s goto again\r\n`+
-		`rmdir /s /q %s >nul 2>&1\r\n`+
-		`del /f /q "%s" >nul 2>&1\r\n`,
-		exePath, installDir, exePath, exePath, installDir, tempBat,
-	)
-	if err := os.WriteFile(tempBat, []byte(script), 0o644); err != nil {
+	if installDir == "" {
+		return nil
+	}
+	if purge {
+		return os.RemoveAll(installDir)
+	}
+	if err := os.Remove(installDir); err != nil && !os.IsNotExist(err) && !errors.Is(err, windows.ERROR_DIR_NOT_EMPTY) {
 		return err
 	}
-	// 启动批处理（新窗口/后台）。
-	_, _ = os.StartProcess("cmd", []string{"cmd", "/c", "start", "", tempBat}, &os.ProcAttr{Files: []*os.File{os.Stdin, os.Stdout, os.Stderr}})
 	return nil
 }
+
+// moveFile 调用 MoveFileEx；dst 为空表示删除（配合 MOVEFILE_DELAY_UNTIL_REBOOT）
+func moveFile(src, dst string, flags uint32) error {
+	from, err := windows.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	var to *uint16
+	if dst != "" {
+		if to, err = windows.UTF16PtrFromString(dst); err != nil {
+			return err
+		}
+	}
+	return windows.MoveFileEx(from, to, flags)
+}
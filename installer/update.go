@@ -0,0 +1,62 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// UpdateManifestSchema 是 UpdateManifest 的格式版本，字段含义变化时递增
+const UpdateManifestSchema = 1
+
+// UpdateManifest 是 Options.EmitUpdateManifest 生成的更新清单（appcast）。
+// 已安装的程序定期下载该文件，与自身版本比较后从 URL 下载新安装器，并用 SHA256 校验
+type UpdateManifest struct {
+	Schema       int      `json:"schema"`
+	ProductName  string   `json:"productName"`
+	Version      string   `json:"version"`
+	URL          string   `json:"url"`
+	SHA256       string   `json:"sha256"`
+	Size         int64    `json:"size"`
+	ReleaseDate  string   `json:"releaseDate"` // RFC 3339（UTC），取打包时间
+	MinOSVersion string   `json:"minOSVersion,omitempty"`
+	RequiredOS   []string `json:"requiredOS,omitempty"`
+	RequiredArch []string `json:"requiredArch,omitempty"`
+}
+
+// validateUpdateOptions 在打包前检查生成更新清单所需的选项，避免打包完成后才报错
+func validateUpdateOptions(opts Options) error {
+	if opts.EmitUpdateManifest == "" {
+		return nil
+	}
+	if opts.Version == "" {
+		return fmt.Errorf("EmitUpdateManifest requires Version")
+	}
+	u, err := url.Parse(opts.UpdateURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("EmitUpdateManifest requires an absolute UpdateURL, got %q", opts.UpdateURL)
+	}
+	return nil
+}
+
+// writeUpdateManifest 按生成结果写出更新清单
+func writeUpdateManifest(opts Options, result BuildResult, built time.Time) error {
+	data, err := json.MarshalIndent(UpdateManifest{
+		Schema:       UpdateManifestSchema,
+		ProductName:  opts.ProductName,
+		Version:      opts.Version,
+		URL:          opts.UpdateURL,
+		SHA256:       result.ResultHash,
+		Size:         result.Size,
+		ReleaseDate:  built.UTC().Format(time.RFC3339),
+		MinOSVersion: opts.UpdateMinOSVersion,
+		RequiredOS:   opts.RequiredOS,
+		RequiredArch: opts.RequiredArch,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(opts.EmitUpdateManifest, append(data, '\n'), 0o644)
+}
@@ -2,17 +2,31 @@ package main
 
 import (
 	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	"exe_installer/installer"
 )
 
-func main() {
-	err := installer.CreateInstaller(
-		"./stub.exe",
-		"./yuumi.exe",
-		"./lol_yuumi_setup_v091.exe",
-		installer.Options{
+// buildConfig 是 build --config 读取的 JSON：stub / payload / out 之外的键直接对应 installer.Options 的字段名
+// （大小写不敏感），如 {"stub": "stub.exe", "payload": "app.exe", "out": "setup.exe", "ProductName": "MyApp"}
+type buildConfig struct {
+	Stub    string `json:"stub"`
+	Payload string `json:"payload"`
+	Out     string `json:"out"`
+	installer.Options
+}
+
+// defaultConfig 不带参数运行时打包的默认安装器（build.ps1 -Mode package 使用）
+func defaultConfig() buildConfig {
+	return buildConfig{
+		Stub:    "./stub.exe",
+		Payload: "./yuumi.exe",
+		Out:     "./lol_yuumi_setup_v091.exe",
+		Options: installer.Options{
 			ProductName:             "lolyuumi",
 			ExeName:                 "yuumi.exe", // 若你的真实文件名不同，改这里
 			CreateDesktopShortcut:   true,
@@ -21,8 +35,156 @@ func main() {
 			ShortcutName:            "悠米助手纯净版",
 			CompressionLevel:        gzip.BestCompression, // 使用最高压缩级别
 		},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		if err := build(defaultConfig()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	switch os.Args[1] {
+	case "build":
+		cfg, err := parseBuildFlags(os.Args[2:])
+		if err == flag.ErrHelp {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := build(cfg); err != nil {
+			log.Fatal(err)
+		}
+	case "help", "-h", "--help":
+		usage()
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `用法:
+  exe_installer                      按 main.go 中的默认配置打包
+  exe_installer build [参数]         按命令行参数打包
+  exe_installer build --config build.json [参数]
+                                     按 JSON 配置打包，命令行参数覆盖配置中的同名项
+
+运行 exe_installer build -h 查看全部参数
+`)
+}
+
+// parseBuildFlags 解析 build 子命令参数。指定 --config 时先读取配置，再用显式给出的参数覆盖
+func parseBuildFlags(args []string) (buildConfig, error) {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	var (
+		config     = fs.String("config", "", "JSON 配置文件（见 README）")
+		stub       = fs.String("stub", "./stub.exe", "stub 路径")
+		payload    = fs.String("exe", "", "要打包的主程序（与 --source-dir 二选一）")
+		sourceDir  = fs.String("source-dir", "", "递归打包的目录")
+		out        = fs.String("out", "", "生成的安装器路径")
+		product    = fs.String("product", "", "产品名称")
+		exeName    = fs.String("exe-name", "", "主程序相对安装目录的路径（默认为 --exe 的文件名）")
+		version    = fs.String("version", "", "版本号")
+		publisher  = fs.String("publisher", "", "发布者")
+		shortcut   = fs.String("shortcut-name", "", "快捷方式名称")
+		installDir = fs.String("install-dir", "", "固定安装目录")
+		scope      = fs.String("scope", "", "默认安装范围：machine 或 user")
+		language   = fs.String("lang", "", "安装界面语言，如 zh-CN、en-US")
+		desktop    = fs.Bool("desktop", false, "创建桌面快捷方式")
+		startMenu  = fs.Bool("start-menu", false, "创建开始菜单快捷方式")
+		level      = fs.Int("level", gzip.DefaultCompression, "gzip 压缩等级（-2 ~ 9，-1 为默认，9 最高）")
+		workers    = fs.Int("workers", 0, "安装时并发写入文件的数量（0 为默认）")
+		icon       = fs.String("icon", "", "安装器图标（.ico）")
+		signingKey = fs.String("signing-key", "", "Ed25519 私钥文件（SaveSigningKey 生成）")
+		url        = fs.String("url", "", "安装器的下载地址，写入更新清单")
+		updateFile = fs.String("update-manifest", "", "更新清单输出路径（需同时指定 --url）")
 	)
+	if err := fs.Parse(args); err != nil {
+		return buildConfig{}, err
+	}
+	if fs.NArg() > 0 {
+		return buildConfig{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	// 配置文件未写 CompressionLevel 时同命令行默认值，而不是 0（不压缩）
+	cfg := buildConfig{Options: installer.Options{CompressionLevel: gzip.DefaultCompression}}
+	if *config != "" {
+		data, err := os.ReadFile(*config)
+		if err != nil {
+			return buildConfig{}, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return buildConfig{}, fmt.Errorf("parse %s: %w", *config, err)
+		}
+	} else {
+		cfg.Stub = *stub
+	}
+
+	// 只应用显式给出的参数，未给出的保留配置文件中的值
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "stub":
+			cfg.Stub = *stub
+		case "exe":
+			cfg.Payload = *payload
+		case "source-dir":
+			cfg.SourceDir = *sourceDir
+		case "out":
+			cfg.Out = *out
+		case "product":
+			cfg.ProductName = *product
+		case "exe-name":
+			cfg.ExeName = *exeName
+		case "version":
+			cfg.Version = *version
+		case "publisher":
+			cfg.Publisher = *publisher
+		case "shortcut-name":
+			cfg.ShortcutName = *shortcut
+		case "install-dir":
+			cfg.InstallDir = *installDir
+		case "scope":
+			cfg.InstallScope = *scope
+		case "lang":
+			cfg.Language = *language
+		case "desktop":
+			cfg.CreateDesktopShortcut = *desktop
+		case "start-menu":
+			cfg.CreateStartMenuShortcut = *startMenu
+		case "level":
+			cfg.CompressionLevel = *level
+		case "workers":
+			cfg.WriteWorkers = *workers
+		case "icon":
+			cfg.IconFile = *icon
+		case "signing-key":
+			if cfg.SigningKey, err = installer.LoadSigningKey(*signingKey); err != nil {
+				err = fmt.Errorf("load signing key: %w", err)
+			}
+		case "url":
+			cfg.UpdateURL = *url
+		case "update-manifest":
+			cfg.EmitUpdateManifest = *updateFile
+		}
+	})
 	if err != nil {
-		log.Fatal(err)
+		return buildConfig{}, err
+	}
+	if cfg.Stub == "" {
+		cfg.Stub = "./stub.exe"
 	}
-}
\ No newline at end of file
+	if cfg.Out == "" {
+		return buildConfig{}, fmt.Errorf("missing --out")
+	}
+	return cfg, nil
+}
+
+// build 按配置生成安装器
+func build(cfg buildConfig) error {
+	return installer.CreateInstaller(cfg.Stub, cfg.Payload, cfg.Out, cfg.Options)
+}